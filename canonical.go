@@ -0,0 +1,150 @@
+package msgpack
+
+import "math"
+
+// The canonical encoding of a value is the shortest one, with
+// non-negative integers always using the uint formats, floats using
+// single precision whenever that is exact and all NaNs being written
+// as the single precision quiet NaN. These functions give the leading
+// byte the canonical encoding uses for a value.
+
+const canonicalNaN = 0x7fc00000
+
+func canonicalExtUint(v uint64) byte {
+	switch {
+	case v <= mask8:
+		return 0xd4
+	case v <= mask16:
+		return 0xd5
+	case v <= mask32:
+		return 0xd6
+	default:
+		return 0xd7
+	}
+}
+
+func canonicalFloat(v float64) byte {
+	if math.IsNaN(v) || float64(float32(v)) == v {
+		return 0xca
+	}
+	return 0xcb
+}
+
+func canonicalInt(v int64) byte {
+	switch {
+	case v >= 0:
+		return canonicalUint(uint64(v))
+	case v >= intFixMin:
+		return byte(v)
+	case v >= int8Min:
+		return 0xd0
+	case v >= int16Min:
+		return 0xd1
+	case v >= int32Min:
+		return 0xd2
+	default:
+		return 0xd3
+	}
+}
+
+func canonicalLength(k Kind, n uint32) byte {
+	switch k {
+	case KindString:
+		switch {
+		case n <= mask5:
+			return 0xa0 | byte(n)
+		case n <= mask8:
+			return 0xd9
+		case n <= mask16:
+			return 0xda
+		default:
+			return 0xdb
+		}
+	case KindBinary:
+		switch {
+		case n <= mask8:
+			return 0xc4
+		case n <= mask16:
+			return 0xc5
+		default:
+			return 0xc6
+		}
+	case KindArray:
+		switch {
+		case n <= mask4:
+			return 0x90 | byte(n)
+		case n <= mask16:
+			return 0xdc
+		default:
+			return 0xdd
+		}
+	case KindMap:
+		switch {
+		case n <= mask4:
+			return 0x80 | byte(n)
+		case n <= mask16:
+			return 0xde
+		default:
+			return 0xdf
+		}
+	default:
+		switch {
+		case n == 1:
+			return 0xd4
+		case n == 2:
+			return 0xd5
+		case n == 4:
+			return 0xd6
+		case n == 8:
+			return 0xd7
+		case n == 16:
+			return 0xd8
+		case n <= mask8:
+			return 0xc7
+		case n <= mask16:
+			return 0xc8
+		default:
+			return 0xc9
+		}
+	}
+}
+
+func canonicalTime(sec, nsec int64) byte {
+	switch {
+	case sec >= 0 && sec <= mask32 && nsec == 0:
+		return 0xd6
+	case sec >= 0 && sec <= mask34:
+		return 0xd7
+	default:
+		return 0xc7
+	}
+}
+
+func canonicalUint(v uint64) byte {
+	switch {
+	case v <= mask7:
+		return byte(v)
+	case v <= mask8:
+		return 0xcc
+	case v <= mask16:
+		return 0xcd
+	case v <= mask32:
+		return 0xce
+	default:
+		return 0xcf
+	}
+}
+
+func (n number) canonical(b byte) bool {
+	switch n.kind {
+	case KindInt:
+		return b == canonicalInt(n.i)
+	case KindUint:
+		return b == canonicalUint(n.u)
+	default:
+		if math.IsNaN(n.f) {
+			return b == 0xca && math.Float32bits(float32(n.f)) == canonicalNaN
+		}
+		return b == canonicalFloat(n.f)
+	}
+}
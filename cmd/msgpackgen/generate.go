@@ -0,0 +1,741 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+const msgpackPath = "github.com/ab36245/go-msgpack"
+
+type generator struct {
+	pkg     *pkg
+	structs []*types.Named
+	imports map[string]string // path -> name
+	fresh   int
+}
+
+func newGenerator(p *pkg, structs []*types.Named) *generator {
+	return &generator{pkg: p, structs: structs}
+}
+
+// A field is one map entry of an encoded struct
+type field struct {
+	name      string
+	key       []byte // name encoded as a msgpack string
+	path      string // selector from the struct, e.g. "Base.ID"
+	typ       types.Type
+	omitEmpty bool
+}
+
+// Returns the generated methods and codecs
+func (g *generator) code() ([]byte, error) {
+	g.imports = map[string]string{}
+	var body bytes.Buffer
+	for _, t := range g.structs {
+		fields, err := g.fields(t)
+		if err != nil {
+			return nil, err
+		}
+		g.codec(&body, t)
+		g.encodeMethod(&body, t, fields)
+		g.decodeMethod(&body, t, fields)
+	}
+	return g.file(body.Bytes())
+}
+
+// Returns round trip tests for the generated codecs
+func (g *generator) tests() ([]byte, error) {
+	g.imports = map[string]string{}
+	var body bytes.Buffer
+	m := g.use(msgpackPath, "msgpack")
+	reflect := g.use("reflect", "reflect")
+	testing := g.use("testing", "testing")
+	for _, t := range g.structs {
+		name := t.Obj().Name()
+		fmt.Fprintf(&body, "func Test%sMsgpack(t *%s.T) {\n", exported(name), testing)
+		fmt.Fprintf(&body, "values := []%s{\n{},\n", name)
+		if sample := g.sample(t, 2); sample != "" {
+			fmt.Fprintf(&body, "%s,\n", strings.TrimPrefix(sample, name))
+		}
+		fmt.Fprintf(&body, "}\n")
+		fmt.Fprintf(&body, "for _, v := range values {\n")
+		fmt.Fprintf(&body, "for _, e := range []*%s.Encoder{%[1]s.NewEncoder(), %[1]s.NewEncoder(%[1]s.WithCanonical())} {\n", m)
+		fmt.Fprintf(&body, "if err := %s.Encode(e, v); err != nil {\nt.Fatal(err)\n}\n", codecName(name))
+		fmt.Fprintf(&body, "d := %s.NewDecoder(e.Bytes())\n", m)
+		fmt.Fprintf(&body, "actual, err := %s.Decode(d)\n", codecName(name))
+		fmt.Fprintf(&body, "if err != nil {\nt.Fatal(err)\n}\n")
+		fmt.Fprintf(&body, "if !d.IsEmpty() {\nt.Fatalf(\"%%d bytes left over\", d.Length())\n}\n")
+		fmt.Fprintf(&body, "if !%s.DeepEqual(actual, v) {\n", reflect)
+		fmt.Fprintf(&body, "t.Fatalf(\"\\nexpected: %%+v\\nactual:   %%+v\\n\", v, actual)\n")
+		fmt.Fprintf(&body, "}\n}\n}\n}\n\n")
+	}
+	return g.file(body.Bytes())
+}
+
+// Adds the header and imports to body and formats the lot
+func (g *generator) file(body []byte) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by msgpackgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", g.pkg.types.Name())
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	// The standard library comes first
+	sort.Slice(paths, func(i, j int) bool {
+		si, sj := isStandard(paths[i]), isStandard(paths[j])
+		if si != sj {
+			return si
+		}
+		return paths[i] < paths[j]
+	})
+	fmt.Fprintf(&b, "import (\n")
+	for i, path := range paths {
+		if i > 0 && isStandard(paths[i-1]) && !isStandard(path) {
+			fmt.Fprintf(&b, "\n")
+		}
+		if name := g.imports[path]; name != path[strings.LastIndex(path, "/")+1:] {
+			fmt.Fprintf(&b, "%s ", name)
+		}
+		fmt.Fprintf(&b, "%q\n", path)
+	}
+	fmt.Fprintf(&b, ")\n\n")
+	b.Write(body)
+	code, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, b.Bytes())
+	}
+	return code, nil
+}
+
+func isStandard(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// Records that path is imported and returns the name to use for it
+func (g *generator) use(path, name string) string {
+	g.imports[path] = name
+	return name
+}
+
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg.types {
+		return ""
+	}
+	return g.use(p.Path(), p.Name())
+}
+
+func (g *generator) typeName(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+// Returns a new local variable name starting with prefix
+func (g *generator) local(prefix string) string {
+	g.fresh++
+	return prefix + strconv.Itoa(g.fresh)
+}
+
+// Lists the fields of t as Marshal sees them, sorted by their encoded
+// names so the map is canonical
+func (g *generator) fields(t *types.Named) ([]field, error) {
+	var fields []field
+	depths := map[string]int{}
+	var walk func(s *types.Struct, prefix string, depth int) error
+	walk = func(s *types.Struct, prefix string, depth int) error {
+		for i := range s.NumFields() {
+			f := s.Field(i)
+			tag := reflect.StructTag(s.Tag(i)).Get("msgpack")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Embedded() && name == "" {
+				ft := f.Type()
+				if p, ok := ft.Underlying().(*types.Pointer); ok {
+					if _, ok := p.Elem().Underlying().(*types.Struct); ok {
+						return fmt.Errorf("%s: embedded pointer %s is not supported", t.Obj().Name(), f.Name())
+					}
+				}
+				if s, ok := ft.Underlying().(*types.Struct); ok {
+					if err := walk(s, prefix+f.Name()+".", depth+1); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if !f.Exported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name()
+			}
+			if d, ok := depths[name]; ok && d <= depth {
+				// Hidden by a field of the same name in an outer
+				// struct, or an earlier one at the same depth
+				continue
+			}
+			depths[name] = depth
+			fields = slices.DeleteFunc(fields, func(f field) bool {
+				return f.name == name
+			})
+			fields = append(fields, field{
+				name:      name,
+				path:      prefix + f.Name(),
+				typ:       f.Type(),
+				omitEmpty: opts == "omitempty",
+			})
+		}
+		return nil
+	}
+	if err := walk(t.Underlying().(*types.Struct), "", 0); err != nil {
+		return nil, err
+	}
+
+	for i := range fields {
+		e := msgpack.NewEncoder(msgpack.WithCanonical())
+		if err := e.PutString(fields[i].name); err != nil {
+			return nil, fmt.Errorf("%s: field %s: %w", t.Obj().Name(), fields[i].name, err)
+		}
+		fields[i].key = e.Bytes()
+	}
+	slices.SortFunc(fields, func(a, b field) int {
+		return bytes.Compare(a.key, b.key)
+	})
+	return fields, nil
+}
+
+func (g *generator) codec(w *bytes.Buffer, t *types.Named) {
+	m := g.use(msgpackPath, "msgpack")
+	name := t.Obj().Name()
+	fmt.Fprintf(w, "// %s encodes and decodes %s without reflection\n", codecName(name), name)
+	fmt.Fprintf(w, "var %s = %s.Codec[%s]{\n", codecName(name), m, name)
+	fmt.Fprintf(w, "Decode: func(d *%s.Decoder) (%s, error) {\n", m, name)
+	fmt.Fprintf(w, "var v %s\nerr := v.DecodeMsgpack(d)\nreturn v, err\n},\n", name)
+	fmt.Fprintf(w, "Encode: func(e *%s.Encoder, v %s) error {\n", m, name)
+	fmt.Fprintf(w, "return v.EncodeMsgpack(e)\n},\n}\n\n")
+}
+
+func (g *generator) encodeMethod(w *bytes.Buffer, t *types.Named, fields []field) {
+	g.fresh = 0
+	m := g.use(msgpackPath, "msgpack")
+	fmt.Fprintf(w, "func (v %s) EncodeMsgpack(e *%s.Encoder) error {\n", t.Obj().Name(), m)
+	required := 0
+	for _, f := range fields {
+		if !f.omitEmpty {
+			required++
+		}
+	}
+	if required == len(fields) {
+		fmt.Fprintf(w, "e.PutMapLength(%d)\n", len(fields))
+	} else {
+		fmt.Fprintf(w, "n := %d\n", required)
+		for _, f := range fields {
+			if f.omitEmpty {
+				fmt.Fprintf(w, "if %s {\nn++\n}\n", g.notZero("v."+f.path, f.typ))
+			}
+		}
+		fmt.Fprintf(w, "e.PutMapLength(uint32(n))\n")
+	}
+	for _, f := range fields {
+		if f.omitEmpty {
+			fmt.Fprintf(w, "if %s {\n", g.notZero("v."+f.path, f.typ))
+		}
+		fmt.Fprintf(w, "if err := e.PutString(%q); err != nil {\nreturn err\n}\n", f.name)
+		g.encode(w, "v."+f.path, f.typ)
+		if f.omitEmpty {
+			fmt.Fprintf(w, "}\n")
+		}
+	}
+	fmt.Fprintf(w, "return nil\n}\n\n")
+}
+
+func (g *generator) decodeMethod(w *bytes.Buffer, t *types.Named, fields []field) {
+	g.fresh = 0
+	m := g.use(msgpackPath, "msgpack")
+	name := t.Obj().Name()
+	fmt.Fprintf(w, "func (v *%s) DecodeMsgpack(d *%s.Decoder) error {\n", name, m)
+	fmt.Fprintf(w, "isNil, err := d.IfNil()\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(w, "if isNil {\n*v = %s{}\nreturn nil\n}\n", name)
+	fmt.Fprintf(w, "n, err := d.GetMapLength()\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(w, "for range n {\n")
+	fmt.Fprintf(w, "if k, err := d.PeekKind(); err != nil {\nreturn err\n} else if k != %s.KindString {\n", m)
+	fmt.Fprintf(w, "// Only a string can name a field\n")
+	fmt.Fprintf(w, "if err := d.Skip(); err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(w, "if err := d.Skip(); err != nil {\nreturn err\n}\ncontinue\n}\n")
+	fmt.Fprintf(w, "key, err := d.GetStringView()\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(w, "switch key {\n")
+	for _, f := range fields {
+		fmt.Fprintf(w, "case %q:\n", f.name)
+		fmt.Fprintf(w, "d.PushKey(%q)\n", f.name)
+		fmt.Fprintf(w, "err = func() error {\n")
+		g.decode(w, "v."+f.path, f.typ)
+		fmt.Fprintf(w, "return nil\n}()\n")
+		fmt.Fprintf(w, "d.Pop()\n")
+	}
+	fmt.Fprintf(w, "default:\nerr = d.Skip()\n}\n")
+	fmt.Fprintf(w, "if err != nil {\nreturn err\n}\n}\n")
+	fmt.Fprintf(w, "return nil\n}\n\n")
+}
+
+// Writes statements encoding x of type t, returning any error
+func (g *generator) encode(w *bytes.Buffer, x string, t types.Type) {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		fmt.Fprintf(w, "if %s == nil {\ne.PutNil()\n} else {\n", x)
+		g.encode(w, "(*"+x+")", p.Elem())
+		fmt.Fprintf(w, "}\n")
+		return
+	}
+	if g.encodes(t) {
+		if _, ok := t.Underlying().(*types.Interface); ok {
+			fmt.Fprintf(w, "if %s == nil {\ne.PutNil()\n} else ", x)
+		}
+		fmt.Fprintf(w, "if err := %s.EncodeMsgpack(e); err != nil {\nreturn err\n}\n", x)
+		return
+	}
+	switch {
+	case isNamed(t, "time", "Time"):
+		fmt.Fprintf(w, "e.PutTime(%s)\n", x)
+		return
+	case isNamed(t, msgpackPath, "Raw"):
+		fmt.Fprintf(w, "if err := e.PutRaw(%s); err != nil {\nreturn err\n}\n", x)
+		return
+	case isNamed(t, msgpackPath, "Value"):
+		fmt.Fprintf(w, "if err := e.PutValue(%s); err != nil {\nreturn err\n}\n", x)
+		return
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			fmt.Fprintf(w, "e.PutBool(%s)\n", convert(x, t, types.Bool))
+			return
+		case info&types.IsInteger != 0 && info&types.IsUnsigned != 0:
+			fmt.Fprintf(w, "e.PutUint(%s)\n", convert(x, t, types.Uint64))
+			return
+		case info&types.IsInteger != 0:
+			fmt.Fprintf(w, "e.PutInt(%s)\n", convert(x, t, types.Int64))
+			return
+		case u.Kind() == types.Float32:
+			fmt.Fprintf(w, "e.PutFloat32(%s)\n", convert(x, t, types.Float32))
+			return
+		case u.Kind() == types.Float64:
+			fmt.Fprintf(w, "e.PutFloat(%s)\n", convert(x, t, types.Float64))
+			return
+		case info&types.IsString != 0:
+			fmt.Fprintf(w, "if err := e.PutString(%s); err != nil {\nreturn err\n}\n", convert(x, t, types.String))
+			return
+		}
+	case *types.Slice:
+		if isByte(u.Elem()) {
+			fmt.Fprintf(w, "if %s == nil {\ne.PutNil()\n} else if err := e.PutBinary(%[1]s); err != nil {\nreturn err\n}\n", x)
+			return
+		}
+		if !isNamedByte(u.Elem()) {
+			item := g.local("x")
+			fmt.Fprintf(w, "if %s == nil {\ne.PutNil()\n} else {\n", x)
+			fmt.Fprintf(w, "e.PutArrayLength(uint32(len(%s)))\n", x)
+			fmt.Fprintf(w, "for _, %s := range %s {\n", item, x)
+			g.encode(w, item, u.Elem())
+			fmt.Fprintf(w, "}\n}\n")
+			return
+		}
+	case *types.Array:
+		if isByte(u.Elem()) {
+			fmt.Fprintf(w, "if err := e.PutBinary(%s[:]); err != nil {\nreturn err\n}\n", x)
+			return
+		}
+		if !isNamedByte(u.Elem()) {
+			item := g.local("x")
+			fmt.Fprintf(w, "e.PutArrayLength(%d)\n", u.Len())
+			fmt.Fprintf(w, "for _, %s := range %s {\n", item, x)
+			g.encode(w, item, u.Elem())
+			fmt.Fprintf(w, "}\n")
+			return
+		}
+	case *types.Map:
+		// The container sorts the entries for a canonical encoder
+		key, item, c := g.local("k"), g.local("x"), g.local("c")
+		fmt.Fprintf(w, "if %s == nil {\ne.PutNil()\n} else {\n", x)
+		fmt.Fprintf(w, "%s := e.BeginMap()\n", c)
+		fmt.Fprintf(w, "for %s, %s := range %s {\n", key, item, x)
+		fmt.Fprintf(w, "if err := func() error {\n")
+		g.encode(w, key, u.Key())
+		g.encode(w, item, u.Elem())
+		fmt.Fprintf(w, "return nil\n}(); err != nil {\n%s.End()\nreturn err\n}\n}\n", c)
+		fmt.Fprintf(w, "if err := %s.End(); err != nil {\nreturn err\n}\n}\n", c)
+		return
+	}
+	// Anything else is left to reflection
+	fmt.Fprintf(w, "if err := e.Encode(%s); err != nil {\nreturn err\n}\n", x)
+}
+
+// Writes statements decoding into x of type t, returning any error
+func (g *generator) decode(w *bytes.Buffer, x string, t types.Type) {
+	m := g.use(msgpackPath, "msgpack")
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		fmt.Fprintf(w, "if isNil, err := d.IfNil(); err != nil {\nreturn err\n} else if isNil {\n%s = nil\n} else {\n", x)
+		fmt.Fprintf(w, "if %s == nil {\n%[1]s = new(%s)\n}\n", x, g.typeName(p.Elem()))
+		g.decode(w, "(*"+x+")", p.Elem())
+		fmt.Fprintf(w, "}\n")
+		return
+	}
+	if g.decodes(t) {
+		fmt.Fprintf(w, "if err := %s.DecodeMsgpack(d); err != nil {\nreturn err\n}\n", x)
+		return
+	}
+	switch {
+	case isNamed(t, msgpackPath, "Raw"):
+		fmt.Fprintf(w, "if raw, err := d.GetRaw(); err != nil {\nreturn err\n} else {\n%s = raw\n}\n", x)
+		return
+	case isNamed(t, msgpackPath, "Value"):
+		fmt.Fprintf(w, "if value, err := d.GetValue(); err != nil {\nreturn err\n} else {\n%s = value\n}\n", x)
+		return
+	}
+
+	// Everything else decodes nil as its zero value
+	ifNotNil := func() {
+		fmt.Fprintf(w, "if isNil, err := d.IfNil(); err != nil {\nreturn err\n} else if isNil {\n%s = %s\n} else ", x, g.zero(t))
+	}
+	get := func(call, result string) {
+		ifNotNil()
+		fmt.Fprintf(w, "if tmp, err := %s; err != nil {\nreturn err\n} else {\n%s = %s\n}\n", call, x, result)
+	}
+	if isNamed(t, "time", "Time") {
+		get("d.GetTime()", "tmp")
+		return
+	}
+	typ := g.typeName(t)
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			get("d.GetBool()", convert("tmp", t, types.Bool))
+			return
+		case info&types.IsInteger != 0:
+			get(fmt.Sprintf("%s.GetInteger[%s](d)", m, typ), "tmp")
+			return
+		case info&types.IsFloat != 0:
+			get(fmt.Sprintf("%s.GetFloatAs[%s](d)", m, typ), "tmp")
+			return
+		case info&types.IsString != 0:
+			get("d.GetString()", convert("tmp", t, types.String))
+			return
+		}
+	case *types.Slice:
+		if isByte(u.Elem()) {
+			get("d.GetBinaryCopy()", "tmp")
+			return
+		}
+		if !isNamedByte(u.Elem()) {
+			s, i, item := g.local("s"), g.local("i"), g.local("x")
+			ifNotNil()
+			fmt.Fprintf(w, "if n, err := d.GetArrayLength(); err != nil {\nreturn err\n} else {\n")
+			fmt.Fprintf(w, "%s := make(%s, 0, d.ArrayCapacity(n))\n", s, typ)
+			fmt.Fprintf(w, "for %s := range int(n) {\n", i)
+			fmt.Fprintf(w, "var %s %s\n", item, g.typeName(u.Elem()))
+			fmt.Fprintf(w, "d.PushIndex(%s)\nerr := func() error {\n", i)
+			g.decode(w, item, u.Elem())
+			fmt.Fprintf(w, "return nil\n}()\nd.Pop()\nif err != nil {\nreturn err\n}\n")
+			fmt.Fprintf(w, "%s = append(%[1]s, %s)\n}\n", s, item)
+			fmt.Fprintf(w, "%s = %s\n}\n", x, s)
+			return
+		}
+	case *types.Array:
+		fmtName := g.use("fmt", "fmt")
+		if isByte(u.Elem()) {
+			ifNotNil()
+			fmt.Fprintf(w, "{\nstart := d.Offset()\n")
+			fmt.Fprintf(w, "if b, err := d.GetBinaryView(); err != nil {\nreturn err\n} else if len(b) != %d {\n", u.Len())
+			fmt.Fprintf(w, "return &%s.DecodeError{Path: d.Path(), Offset: start, Err: %s.Errorf(\"expected %d bytes, got %%d\", len(b))}\n", m, fmtName, u.Len())
+			fmt.Fprintf(w, "} else {\ncopy(%s[:], b)\n}\n}\n", x)
+			return
+		}
+		if !isNamedByte(u.Elem()) {
+			i := g.local("i")
+			ifNotNil()
+			fmt.Fprintf(w, "{\nstart := d.Offset()\n")
+			fmt.Fprintf(w, "n, err := d.GetArrayLength()\nif err != nil {\nreturn err\n}\n")
+			fmt.Fprintf(w, "if n != %d {\n", u.Len())
+			fmt.Fprintf(w, "return &%s.DecodeError{Path: d.Path(), Offset: start, Err: %s.Errorf(\"expected array of %d items, got %%d\", n)}\n}\n", m, fmtName, u.Len())
+			fmt.Fprintf(w, "for %s := range %d {\n", i, u.Len())
+			fmt.Fprintf(w, "d.PushIndex(%s)\nerr := func() error {\n", i)
+			g.decode(w, fmt.Sprintf("%s[%s]", x, i), u.Elem())
+			fmt.Fprintf(w, "return nil\n}()\nd.Pop()\nif err != nil {\nreturn err\n}\n}\n}\n")
+			return
+		}
+	case *types.Map:
+		mp, key, item := g.local("m"), g.local("k"), g.local("x")
+		ifNotNil()
+		fmt.Fprintf(w, "if n, err := d.GetMapLength(); err != nil {\nreturn err\n} else {\n")
+		fmt.Fprintf(w, "%s := make(%s, d.MapCapacity(n))\n", mp, typ)
+		fmt.Fprintf(w, "for range n {\n")
+		fmt.Fprintf(w, "var %s %s\n", key, g.typeName(u.Key()))
+		g.decode(w, key, u.Key())
+		fmt.Fprintf(w, "var %s %s\n", item, g.typeName(u.Elem()))
+		fmt.Fprintf(w, "d.PushKeyValue(%s)\nerr := func() error {\n", g.keyValue(key, u.Key()))
+		g.decode(w, item, u.Elem())
+		fmt.Fprintf(w, "return nil\n}()\nd.Pop()\nif err != nil {\nreturn err\n}\n")
+		fmt.Fprintf(w, "%s[%s] = %s\n}\n", mp, key, item)
+		fmt.Fprintf(w, "%s = %s\n}\n", x, mp)
+		return
+	}
+	// Anything else is left to reflection
+	fmt.Fprintf(w, "if err := d.Decode(&%s); err != nil {\nreturn err\n}\n", x)
+}
+
+// Returns a condition which is true when x of type t isn't the zero
+// value, as reflect.Value.IsZero sees it
+func (g *generator) notZero(x string, t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return x
+		case info&types.IsInteger != 0:
+			return x + " != 0"
+		case info&types.IsFloat != 0:
+			// Negative zero isn't zero
+			return fmt.Sprintf("%s.Float64bits(float64(%s)) != 0", g.use("math", "math"), x)
+		case info&types.IsString != 0:
+			return x + ` != ""`
+		}
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface, *types.Chan, *types.Signature:
+		return x + " != nil"
+	case *types.Struct, *types.Array:
+		if comparable(t) {
+			return fmt.Sprintf("%s != (%s{})", x, g.typeName(t))
+		}
+	}
+	return fmt.Sprintf("!%s.ValueOf(%s).IsZero()", g.use("reflect", "reflect"), x)
+}
+
+// Returns the zero value of t
+func (g *generator) zero(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return "false"
+		case info&types.IsString != 0:
+			return `""`
+		default:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return g.typeName(t) + "{}"
+	default:
+		return "nil"
+	}
+}
+
+// Returns an expression converting map key x of type t to a Value for
+// the decoder path, which only formats it if the path is needed. Keys
+// of named types may format themselves differently so are formatted
+// straight away.
+func (g *generator) keyValue(x string, t types.Type) string {
+	m := g.use(msgpackPath, "msgpack")
+	if b, ok := t.(*types.Basic); ok {
+		info := b.Info()
+		switch {
+		case info&types.IsString != 0:
+			return fmt.Sprintf("%s.StringValue(%s)", m, x)
+		case info&types.IsBoolean != 0:
+			return fmt.Sprintf("%s.BoolValue(%s)", m, x)
+		case info&types.IsUnsigned != 0:
+			return fmt.Sprintf("%s.UintValue(uint64(%s))", m, x)
+		case info&types.IsInteger != 0:
+			return fmt.Sprintf("%s.IntValue(int64(%s))", m, x)
+		case b.Kind() == types.Float64:
+			return fmt.Sprintf("%s.FloatValue(%s)", m, x)
+		}
+	}
+	return fmt.Sprintf("%s.StringValue(%s.Sprint(%s))", m, g.use("fmt", "fmt"), x)
+}
+
+// Returns an expression for a value of t, with depth limiting how far
+// into nested structs it goes, or "" if there is nothing useful to
+// put in a test
+func (g *generator) sample(t types.Type, depth int) string {
+	typ := g.typeName(t)
+	if isNamed(t, "time", "Time") {
+		return g.use("time", "time") + ".Unix(1, 0).UTC()"
+	}
+	if isNamed(t, msgpackPath, "Raw") || isNamed(t, msgpackPath, "Value") {
+		return ""
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return "true"
+		case info&types.IsInteger != 0:
+			return "1"
+		case info&types.IsFloat != 0:
+			return "1.5"
+		case info&types.IsString != 0:
+			return `"a"`
+		}
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Struct); ok {
+			if s := g.sample(u.Elem(), depth); s != "" {
+				return "&" + s
+			}
+		}
+	case *types.Slice, *types.Array:
+		if s := g.sample(u.(interface{ Elem() types.Type }).Elem(), depth); s != "" {
+			return fmt.Sprintf("%s{%s}", typ, s)
+		}
+	case *types.Map:
+		k, v := g.sample(u.Key(), depth), g.sample(u.Elem(), depth)
+		if k != "" && v != "" {
+			return fmt.Sprintf("%s{%s: %s}", typ, k, v)
+		}
+	case *types.Struct:
+		named, ok := t.(*types.Named)
+		if !ok || depth == 0 || !slices.Contains(g.structs, named) {
+			return ""
+		}
+		fields, err := g.fields(named)
+		if err != nil {
+			return ""
+		}
+		return g.sampleStruct(named, u, fields, "", depth)
+	}
+	return ""
+}
+
+// Returns a literal of struct s (the underlying type of t) setting
+// those of fields found under prefix
+func (g *generator) sampleStruct(t types.Type, s *types.Struct, fields []field, prefix string, depth int) string {
+	var values []string
+	for i := range s.NumFields() {
+		f := s.Field(i)
+		path := prefix + f.Name()
+		var value string
+		if inner, ok := f.Type().Underlying().(*types.Struct); ok && f.Embedded() {
+			value = g.sampleStruct(f.Type(), inner, fields, path+".", depth)
+		} else if slices.ContainsFunc(fields, func(f field) bool { return f.path == path }) {
+			value = g.sample(f.Type(), depth-1)
+		}
+		if value != "" {
+			values = append(values, f.Name()+": "+value)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s{%s}", g.typeName(t), strings.Join(values, ", "))
+}
+
+// Reports whether the generated code calls EncodeMsgpack for t
+func (g *generator) encodes(t types.Type) bool {
+	return g.generated(t) || hasMethod(t, "EncodeMsgpack")
+}
+
+// Reports whether the generated code calls DecodeMsgpack for t
+func (g *generator) decodes(t types.Type) bool {
+	if g.generated(t) {
+		return true
+	}
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		return false
+	}
+	return hasMethod(types.NewPointer(t), "DecodeMsgpack")
+}
+
+func (g *generator) generated(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && slices.Contains(g.structs, named)
+}
+
+// Reports whether t or *t has the method
+func hasMethod(t types.Type, name string) bool {
+	if types.NewMethodSet(t).Lookup(nil, name) != nil {
+		return true
+	}
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		return false
+	}
+	if _, ok := t.(*types.Pointer); ok {
+		return false
+	}
+	return types.NewMethodSet(types.NewPointer(t)).Lookup(nil, name) != nil
+}
+
+func isNamed(t types.Type, path, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name
+}
+
+func isByte(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Kind() == types.Uint8
+}
+
+// A named type based on byte, which reflection would still encode as
+// binary but can't be converted to []byte, so is left to reflection
+func isNamedByte(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Uint8 && !isByte(t)
+}
+
+// Reports whether values of t can be compared with == without any
+// chance of a panic from an interface holding something that can't
+func comparable(t types.Type) bool {
+	if !types.Comparable(t) {
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		return false
+	case *types.Array:
+		return comparable(u.Elem())
+	case *types.Struct:
+		for i := range u.NumFields() {
+			if !comparable(u.Field(i).Type()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Converts x of type t to the basic type kind, unless it already is
+func convert(x string, t types.Type, kind types.BasicKind) string {
+	if b, ok := t.(*types.Basic); ok && b.Kind() == kind {
+		return x
+	}
+	return fmt.Sprintf("%s(%s)", types.Typ[kind].Name(), x)
+}
+
+// The name of the codec variable for type name
+func codecName(name string) string {
+	return name + "Codec"
+}
+
+func exported(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const annotation = "//msgpack:generate"
+
+type pkg struct {
+	fset   *token.FileSet
+	files  []*ast.File
+	types  *types.Package
+	marked []string
+}
+
+// Parses and type checks the package in dir, leaving out its tests and
+// the files named in skip (which are about to be regenerated). The
+// rest of the package may well use what is about to be generated for
+// the marked types and those named, so that is declared for the type
+// checker.
+func load(dir string, skip, names []string) (*pkg, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := &pkg{fset: token.NewFileSet()}
+	var structs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if slices.Contains(skip, name) {
+			continue
+		}
+		f, err := parser.ParseFile(p.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.files = append(p.files, f)
+		p.marked = append(p.marked, marked(f)...)
+		structs = append(structs, structNames(f)...)
+	}
+	if len(p.files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	// Anything which isn't a struct is left for find to report
+	var generated []string
+	for _, name := range append(p.marked, names...) {
+		if slices.Contains(structs, name) && !slices.Contains(generated, name) {
+			generated = append(generated, name)
+		}
+	}
+	files := p.files
+	if len(generated) > 0 {
+		src := stub(p.files[0].Name.Name, generated)
+		f, err := parser.ParseFile(p.fset, filepath.Join(dir, "<generated>"), src, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files[:len(files):len(files)], f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(p.fset, "source", nil)}
+	p.types, err = conf.Check(p.files[0].Name.Name, p.fset, files, nil)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Returns source declaring the codec and methods generated for each
+// of the named types, without any bodies worth speaking of
+func stub(pkgName string, names []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\nimport msgpack %q\n\n", pkgName, msgpackPath)
+	for _, name := range names {
+		fmt.Fprintf(&b, "var %s msgpack.Codec[%s]\n\n", codecName(name), name)
+		fmt.Fprintf(&b, "func (%s) EncodeMsgpack(*msgpack.Encoder) error { return nil }\n\n", name)
+		fmt.Fprintf(&b, "func (*%s) DecodeMsgpack(*msgpack.Decoder) error { return nil }\n\n", name)
+	}
+	return b.String()
+}
+
+// Returns the names of the non-generic structs declared in f
+func structNames(f *ast.File) []string {
+	var names []string
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.TypeSpec)
+			if _, ok := spec.Type.(*ast.StructType); ok && spec.TypeParams == nil {
+				names = append(names, spec.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Returns the names of the types declared in f with the annotation
+func marked(f *ast.File) []string {
+	var names []string
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.TypeSpec)
+			doc := spec.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			if doc == nil {
+				continue
+			}
+			for _, c := range doc.List {
+				if strings.TrimSpace(c.Text) == annotation {
+					names = append(names, spec.Name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Looks up the marked types and those named, which must all be structs
+func (p *pkg) find(names []string) ([]*types.Named, error) {
+	var structs []*types.Named
+	for _, name := range append(p.marked, names...) {
+		obj := p.types.Scope().Lookup(name)
+		if obj == nil {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a named type", name)
+		}
+		if _, ok := named.Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("%s is not a struct", name)
+		}
+		if named.TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("%s is generic", name)
+		}
+		if !slices.Contains(structs, named) {
+			structs = append(structs, named)
+		}
+	}
+	return structs, nil
+}
@@ -0,0 +1,89 @@
+// Msgpackgen generates msgpack codecs for Go structs.
+//
+// It is meant to be run by go generate from a package containing
+// structs marked with a //msgpack:generate comment:
+//
+//	//go:generate go run github.com/ab36245/go-msgpack/cmd/msgpackgen
+//
+//	//msgpack:generate
+//	type User struct {
+//		ID    int64  `msgpack:"id"`
+//		Email string `msgpack:"email,omitempty"`
+//	}
+//
+// For each such struct T it writes EncodeMsgpack and DecodeMsgpack
+// methods, which make T a msgpack.Marshaler and *T a
+// msgpack.Unmarshaler, and a TCodec variable of type msgpack.Codec[T].
+// The encoding is the same as msgpack.Marshal produces, with the keys
+// in canonical order, but without any use of reflection except for
+// fields of types it has no direct calls for, such as interfaces and
+// structs which aren't generated themselves. Decoding doesn't check
+// the order of the keys, even for a strict canonical decoder. Round
+// trip tests for the generated code are written alongside it.
+//
+// Usage:
+//
+//	msgpackgen [-dir dir] [-output file] [-tests file] [-type T,...]
+//
+// Types given with -type are generated as well as those marked.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "package `directory`")
+	output := flag.String("output", "msgpack_gen.go", "generated code `file`, relative to dir")
+	tests := flag.String("tests", "msgpack_gen_test.go", "generated tests `file`, relative to dir (empty for none)")
+	types := flag.String("type", "", "comma-separated `names` of extra types to generate")
+	flag.Parse()
+
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+	if err := run(*dir, *output, *tests, names); err != nil {
+		fmt.Fprintf(os.Stderr, "msgpackgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, output, tests string, names []string) error {
+	skip := []string{filepath.Base(output)}
+	if tests != "" {
+		skip = append(skip, filepath.Base(tests))
+	}
+	pkg, err := load(dir, skip, names)
+	if err != nil {
+		return err
+	}
+	structs, err := pkg.find(names)
+	if err != nil {
+		return err
+	}
+	if len(structs) == 0 {
+		return fmt.Errorf("no types to generate in %s", dir)
+	}
+
+	g := newGenerator(pkg, structs)
+	code, err := g.code()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, output), code, 0o644); err != nil {
+		return err
+	}
+	if tests == "" {
+		return nil
+	}
+	code, err = g.tests()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, tests), code, 0o644)
+}
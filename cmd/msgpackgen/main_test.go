@@ -0,0 +1,72 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("uses generated", func(t *testing.T) {
+		p, err := load("testdata/uses", nil, []string{"Named"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		structs, err := p.find([]string{"Named"})
+		if err != nil || len(structs) != 2 {
+			t.Fatalf("found %v, %v", structs, err)
+		}
+	})
+
+	t.Run("not generated", func(t *testing.T) {
+		if _, err := load("testdata/uses", nil, nil); err == nil {
+			t.Fatal("NamedCodec isn't generated so should be undefined")
+		}
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		if _, err := load("testdata/typo", nil, nil); err == nil {
+			t.Fatal("TypoCodec should be undefined")
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	dir, err := os.MkdirTemp("testdata", "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	src, err := os.ReadFile("testdata/uses/uses.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "uses.go"), src, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(dir, "msgpack_gen.go", "msgpack_gen_test.go", []string{"Named"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The package has to type check as it is, now the code exists
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range pkgs {
+		var files []*ast.File
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+		conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+		if _, err := conf.Check(pkg.Name, fset, files, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package typo
+
+//msgpack:generate
+type Point struct {
+	X, Y int
+}
+
+var _ = PointCodec
+
+var _ = TypoCodec
@@ -0,0 +1,21 @@
+package uses
+
+import "github.com/ab36245/go-msgpack"
+
+//msgpack:generate
+type Point struct {
+	X, Y int
+}
+
+type Named struct {
+	Name string
+	At   Point
+}
+
+// Uses what is generated before it has been
+var (
+	_ msgpack.Marshaler   = Point{}
+	_ msgpack.Unmarshaler = &Named{}
+	_                     = PointCodec
+	_                     = NamedCodec
+)
@@ -1,6 +1,187 @@
 package msgpack
 
+import (
+	"fmt"
+)
+
 type Codec[T any] struct {
 	Decode func(*Decoder) (T, error)
 	Encode func(*Encoder, T) error
 }
+
+// Encodes a slice as an array of values encoded by c. A nil slice is
+// encoded as nil, and nil decodes as a nil slice.
+func SliceOf[T any](c Codec[T]) Codec[[]T] {
+	return Codec[[]T]{
+		Decode: func(d *Decoder) ([]T, error) {
+			if isNil, err := d.IfNil(); isNil || err != nil {
+				return nil, err
+			}
+			n, err := d.GetArrayLength()
+			if err != nil {
+				return nil, err
+			}
+			return decodeItems(d, int(n), c)
+		},
+		Encode: func(e *Encoder, v []T) error {
+			if v == nil {
+				e.PutNil()
+				return nil
+			}
+			return encodeItems(e, v, c)
+		},
+	}
+}
+
+// Encodes a slice of exactly n values as an array. Decoding an array
+// of any other length is an error.
+func ArrayOf[T any](n int, c Codec[T]) Codec[[]T] {
+	return Codec[[]T]{
+		Decode: func(d *Decoder) ([]T, error) {
+			start := d.offset
+			m, err := d.GetArrayLength()
+			if err != nil {
+				return nil, err
+			}
+			if int(m) != n {
+				return nil, d.fail(fmt.Errorf("expected array of %d items, got %d", n, m), start)
+			}
+			return decodeItems(d, n, c)
+		},
+		Encode: func(e *Encoder, v []T) error {
+			if len(v) != n {
+				return fmt.Errorf("expected %d items to encode, got %d", n, len(v))
+			}
+			return encodeItems(e, v, c)
+		},
+	}
+}
+
+// Encodes a Go map as a map of keys encoded by k and values encoded by
+// v. A canonical encoder sorts the keys. A nil map is encoded as nil,
+// and nil decodes as a nil map.
+func MapOf[K comparable, V any](k Codec[K], v Codec[V]) Codec[map[K]V] {
+	return Codec[map[K]V]{
+		Decode: func(d *Decoder) (map[K]V, error) {
+			if isNil, err := d.IfNil(); isNil || err != nil {
+				return nil, err
+			}
+			n, err := d.GetMapLength()
+			if err != nil {
+				return nil, err
+			}
+			m := make(map[K]V, d.MapCapacity(n))
+			var prev []byte
+			for range n {
+				start := d.offset
+				key, curr, err := decodeKey(d, prev, func() (K, error) {
+					return k.Decode(d)
+				})
+				if err != nil {
+					return nil, err
+				}
+				prev = curr
+				if _, ok := m[key]; ok {
+					return nil, d.fail(fmt.Errorf("duplicate map key %v", key), start)
+				}
+				d.PushKeyValue(keyValue(key))
+				value, err := v.Decode(d)
+				d.Pop()
+				if err != nil {
+					return nil, err
+				}
+				m[key] = value
+			}
+			return m, nil
+		},
+		Encode: func(e *Encoder, m map[K]V) error {
+			if m == nil {
+				e.PutNil()
+				return nil
+			}
+			keys := make([]K, 0, len(m))
+			for key := range m {
+				keys = append(keys, key)
+			}
+			return e.putMap(len(keys),
+				func(e *Encoder, i int) error {
+					return k.Encode(e, keys[i])
+				},
+				func(e *Encoder, i int) error {
+					return v.Encode(e, m[keys[i]])
+				},
+			)
+		},
+	}
+}
+
+// Encodes a nil pointer as nil and any other pointer as the value it
+// points to
+func PtrOf[T any](c Codec[T]) Codec[*T] {
+	return Codec[*T]{
+		Decode: func(d *Decoder) (*T, error) {
+			if isNil, err := d.IfNil(); isNil || err != nil {
+				return nil, err
+			}
+			v, err := c.Decode(d)
+			if err != nil {
+				return nil, err
+			}
+			return &v, nil
+		},
+		Encode: func(e *Encoder, v *T) error {
+			if v == nil {
+				e.PutNil()
+				return nil
+			}
+			return c.Encode(e, *v)
+		},
+	}
+}
+
+// Encodes the zero value of T as nil, and decodes nil as the zero value
+func Nullable[T comparable](c Codec[T]) Codec[T] {
+	return Codec[T]{
+		Decode: func(d *Decoder) (T, error) {
+			if isNil, err := d.IfNil(); isNil || err != nil {
+				return *new(T), err
+			}
+			return c.Decode(d)
+		},
+		Encode: func(e *Encoder, v T) error {
+			if v == *new(T) {
+				e.PutNil()
+				return nil
+			}
+			return c.Encode(e, v)
+		},
+	}
+}
+
+func decodeItems[T any](d *Decoder, n int, c Codec[T]) ([]T, error) {
+	items := make([]T, 0, d.ArrayCapacity(uint32(n)))
+	for i := range n {
+		d.PushIndex(i)
+		item, err := c.Decode(d)
+		d.Pop()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func encodeItems[T any](e *Encoder, items []T, c Codec[T]) error {
+	n := len(items)
+	if n > mask32 {
+		return fmt.Errorf("array (%d items) is %w", n, ErrTooLong)
+	}
+	e.PutArrayLength(uint32(n))
+	for _, item := range items {
+		if err := c.Encode(e, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Room left for the header of a container until its length is known
+const containerHeaderSize = 5
+
+// A Container is an array or map whose elements are being written
+// without knowing in advance how many there will be. Each element (or
+// each key and value for a map) is written with the usual Put methods
+// and End fills in the length.
+//
+// Containers can be nested but must be ended in the reverse of the
+// order they were begun. A stream encoder holds everything back until
+// the outermost container is ended. Clear and Reset discard any
+// containers still open, after which ending them fails.
+type Container struct {
+	e     *Encoder
+	kind  Kind
+	depth int
+	start int
+	epoch int
+}
+
+func (e *Encoder) BeginArray() Container {
+	return e.begin(KindArray)
+}
+
+func (e *Encoder) BeginMap() Container {
+	return e.begin(KindMap)
+}
+
+func (e *Encoder) begin(kind Kind) Container {
+	start := e.Len()
+	e.open = append(e.open, start)
+	e.bytes = append(e.bytes, make([]byte, containerHeaderSize)...)
+	return Container{e: e, kind: kind, depth: len(e.open), start: start, epoch: e.epoch}
+}
+
+// Counts the elements written since the container was begun and gives
+// it a header. Unless the encoder uses fixed headers the smallest
+// header is chosen and the elements are moved along to meet it. If
+// the elements turn out not to be well formed the whole container is
+// dropped. Either way the elements may move, so any marks taken since
+// the container was begun are released.
+func (c Container) End() error {
+	e := c.e
+	if e == nil || c.epoch != e.epoch || c.depth != len(e.open) || e.open[c.depth-1] != c.start {
+		return fmt.Errorf("%s ended out of order: %w", c.kind, ErrUnbalanced)
+	}
+	start := c.start - e.flushed
+	e.open = e.open[:c.depth-1]
+	e.releaseMarks(c.start)
+
+	body := e.bytes[start+containerHeaderSize:]
+	n, err := countValues(body)
+	if err != nil {
+		e.bytes = e.bytes[:start]
+		return err
+	}
+	if c.kind == KindMap {
+		if n%2 != 0 {
+			e.bytes = e.bytes[:start]
+			return fmt.Errorf("map has a key with no value")
+		}
+		n /= 2
+	}
+	if n > mask32 {
+		e.bytes = e.bytes[:start]
+		return fmt.Errorf("%s (%d items) is %w", c.kind, n, ErrTooLong)
+	}
+
+	if c.kind == KindMap && e.canonical {
+		if err := e.sortEntries(start, n); err != nil {
+			return err
+		}
+	} else {
+		var buf [containerHeaderSize]byte
+		header := containerHeader(buf[:0], c.kind, uint32(n), e.fixedHeaders && !e.canonical)
+		if len(header) < containerHeaderSize {
+			end := start + len(header) + copy(e.bytes[start+len(header):], body)
+			e.bytes = e.bytes[:end]
+		}
+		copy(e.bytes[start:], header)
+	}
+	e.spill()
+	return nil
+}
+
+// Rewrites the map of n entries at start with its keys in canonical
+// order
+func (e *Encoder) sortEntries(start, n int) error {
+	d := NewDecoder(e.bytes[start+containerHeaderSize:])
+	keys := make([]Raw, n)
+	values := make([]Raw, n)
+	for i := range n {
+		keys[i], _ = d.GetRaw()
+		values[i], _ = d.GetRaw()
+	}
+	sorted := NewEncoder(WithCanonical())
+	err := sorted.putMap(n,
+		func(s *Encoder, i int) error {
+			s.writeBytes(keys[i])
+			return nil
+		},
+		func(s *Encoder, i int) error {
+			s.writeBytes(values[i])
+			return nil
+		},
+	)
+	if err != nil {
+		e.bytes = e.bytes[:start]
+		return err
+	}
+	e.bytes = append(e.bytes[:start], sorted.bytes...)
+	return nil
+}
+
+func containerHeader(b []byte, kind Kind, n uint32, fixed bool) []byte {
+	fix, b16, b32 := byte(0x90), byte(0xdc), byte(0xdd)
+	if kind == KindMap {
+		fix, b16, b32 = 0x80, 0xde, 0xdf
+	}
+	switch {
+	case fixed:
+		return binary.BigEndian.AppendUint32(append(b, b32), n)
+	case n <= mask4:
+		return append(b, fix|byte(n))
+	case n <= mask16:
+		return binary.BigEndian.AppendUint16(append(b, b16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, b32), n)
+	}
+}
+
+// Counts the complete values in b
+func countValues(b []byte) (int, error) {
+	d := NewDecoder(b)
+	n := 0
+	for !d.IsEmpty() {
+		if err := d.Skip(); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, nil
+}
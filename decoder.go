@@ -1,18 +1,88 @@
 package msgpack
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
+	"slices"
 	"time"
+	"unicode"
+	"unsafe"
 )
 
-func NewDecoder(bytes []byte) *Decoder {
-	return &Decoder{bytes}
+func NewDecoder(bytes []byte, opts ...DecoderOption) *Decoder {
+	// Small enough to be inlined, so a decoder which doesn't escape
+	// its caller isn't allocated
+	d := &Decoder{bytes: bytes}
+	if len(opts) > 0 {
+		d.decoderOptions = applyDecoderOptions(opts)
+	}
+	return d
+}
+
+// A stream decoder reads from r as it needs more bytes. Running out of
+// input before the first byte of a top-level value is reported as
+// io.EOF; running out part way through a value is an error which
+// wraps io.ErrUnexpectedEOF.
+func NewStreamDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	d := NewDecoder(nil, opts...)
+	d.r = r
+	return d
+}
+
+type DecoderOption func(*decoderOptions)
+
+// Only accept values encoded the way a canonical encoder would
+// encode them
+func WithStrictCanonical() DecoderOption {
+	return func(o *decoderOptions) {
+		o.strictCanonical = true
+	}
+}
+
+// Only accept numbers encoded in the family (int, uint or float)
+// being asked for
+func WithStrictNumbers() DecoderOption {
+	return func(o *decoderOptions) {
+		o.strictNumbers = true
+	}
+}
+
+type decoderOptions struct {
+	strictCanonical bool
+	strictNumbers   bool
+}
+
+// Kept out of line so NewDecoder can be inlined
+//
+//go:noinline
+func applyDecoderOptions(opts []DecoderOption) decoderOptions {
+	var o decoderOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
 
 type Decoder struct {
-	bytes []byte
+	bytes  []byte
+	offset int
+	path   []segment
+	decoderOptions
+
+	// Values still owed to the arrays and maps whose headers have
+	// been read
+	pending int
+
+	// Streaming
+	r         io.Reader
+	buf       []byte
+	rerr      error
+	capturing int
+	captured  capture
+	payload   *payload
 }
 
 func (d *Decoder) Bytes() []byte {
@@ -20,6 +90,7 @@ func (d *Decoder) Bytes() []byte {
 }
 
 func (d *Decoder) IsEmpty() bool {
+	d.fill(1)
 	return len(d.bytes) == 0
 }
 
@@ -27,65 +98,83 @@ func (d *Decoder) Length() int {
 	return len(d.bytes)
 }
 
+// Returns the capacity to allocate for an array of n items whose header
+// has just been read. Every item takes at least one byte, so the length
+// is trusted no further than the input left could hold.
+func (d *Decoder) ArrayCapacity(n uint32) int {
+	return min(int(n), d.Length())
+}
+
+// Returns the capacity to allocate for a map of n entries whose header
+// has just been read, which like ArrayCapacity is limited by the input
+// left as each entry takes at least two bytes
+func (d *Decoder) MapCapacity(n uint32) int {
+	return min(int(n), d.Length()/2)
+}
+
 func (d *Decoder) GetArrayLength() (uint32, error) {
-	b, err := d.readByte()
+	return d.getLength(KindArray)
+}
+
+// Returns the bytes of a binary value. For a decoder created with
+// NewDecoder these are part of the input, as with GetBinaryView; a
+// stream decoder returns a copy. Use GetBinaryCopy or GetBinaryView to
+// be explicit.
+func (d *Decoder) GetBinary() ([]byte, error) {
+	n, err := d.getLength(KindBinary)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	if b&0xf0 == 0x90 {
-		return uint32(b & 0x0f), nil
+	return d.readBytes(int(n))
+}
+
+// Returns the bytes of a binary value in a newly allocated slice
+func (d *Decoder) GetBinaryCopy() ([]byte, error) {
+	n, err := d.getLength(KindBinary)
+	if err != nil {
+		return nil, err
 	}
-	switch b {
-	case 0xdc:
-		n, err := d.readUint16()
-		if err != nil {
-			return 0, err
-		}
-		return uint32(n), nil
-	case 0xdd:
-		n, err := d.readUint32()
-		if err != nil {
-			return 0, err
-		}
-		return n, nil
-	default:
-		return invalid[uint32]("array length", b)
+	bytes, err := d.readBytes(int(n))
+	if err != nil {
+		return nil, err
 	}
+	if d.r == nil {
+		// readBytes returns part of the input
+		bytes = slices.Clone(bytes)
+	}
+	return bytes, nil
 }
 
-func (d *Decoder) GetBinary() ([]byte, error) {
-	b, err := d.readByte()
+// Returns the bytes of a binary value without copying them.
+//
+// For a decoder created with NewDecoder the slice is part of the
+// input, so it is only valid for as long as the input is and changes
+// if the input does. For a stream decoder it is part of the decoder's
+// buffer and is only valid until the decoder is next used, except
+// that a large value is copied rather than buffered. In either case
+// the slice must not be modified, although appending to it is safe.
+func (d *Decoder) GetBinaryView() ([]byte, error) {
+	n, err := d.getLength(KindBinary)
 	if err != nil {
 		return nil, err
 	}
-	var size int
-	switch b {
-	case 0xc4:
-		n, err := d.readUint8()
-		if err != nil {
-			return nil, err
-		}
-		size = int(n)
-	case 0xc5:
-		n, err := d.readUint16()
-		if err != nil {
-			return nil, err
-		}
-		size = int(n)
-	case 0xc6:
-		n, err := d.readUint32()
-		if err != nil {
-			return nil, err
-		}
-		size = int(n)
-	default:
-		return invalid[[]byte]("binary", b)
+	return d.viewBytes(int(n))
+}
+
+// Returns a reader over the payload of a binary value along with its
+// length. For a stream decoder the payload is read from the stream in
+// chunks as the reader is read; any of it left unread is skipped when
+// the decoder is next used.
+func (d *Decoder) GetBinaryReader() (io.Reader, int64, error) {
+	n, err := d.getLength(KindBinary)
+	if err != nil {
+		return nil, 0, err
 	}
-	return d.readBytes(size)
+	return d.payloadReader(int64(n))
 }
 
 func (d *Decoder) GetBool() (bool, error) {
-	b, err := d.readByte()
+	b, err := d.readLead()
 	if err != nil {
 		return false, err
 	}
@@ -95,182 +184,149 @@ func (d *Decoder) GetBool() (bool, error) {
 	case 0xc3:
 		return true, nil
 	default:
-		return invalid[bool]("bool", b)
+		return invalid[bool](d, KindBool, b)
+	}
+}
+
+func (d *Decoder) GetExt() (int8, []byte, error) {
+	n, err := d.getLength(KindExt)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Types are signed; -1 to -128 are reserved by the spec
+	// (e.g. -1 for timestamps) and are returned as is
+	typ, err := d.readInt8()
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := d.readBytes(int(n))
+	if err != nil {
+		return 0, nil, err
 	}
+	return typ, data, nil
 }
 
 func (d *Decoder) GetExtUint() (byte, uint64, error) {
-	b, err := d.readByte()
+	start := d.offset
+	b, err := d.readLead()
 	if err != nil {
 		return 0, 0, err
 	}
+	if b < 0xd4 || b > 0xd7 {
+		return invalid2[byte, uint64](d, KindExt, b)
+	}
 	typ, err := d.readByte()
 	if err != nil {
 		return 0, 0, err
 	}
+	var n uint64
 	switch b {
 	case 0xd4:
-		n, err := d.readUint8()
+		v, err := d.readUint8()
 		if err != nil {
 			return 0, 0, err
 		}
-		return typ, uint64(n), nil
+		n = uint64(v)
 	case 0xd5:
-		n, err := d.readUint16()
+		v, err := d.readUint16()
 		if err != nil {
 			return 0, 0, err
 		}
-		return typ, uint64(n), nil
+		n = uint64(v)
 	case 0xd6:
-		n, err := d.readUint32()
+		v, err := d.readUint32()
 		if err != nil {
 			return 0, 0, err
 		}
-		return typ, uint64(n), nil
-	case 0xd7:
-		n, err := d.readUint64()
+		n = uint64(v)
+	default:
+		v, err := d.readUint64()
 		if err != nil {
 			return 0, 0, err
 		}
-		return typ, n, nil
-	default:
-		return invalid2[byte, uint64]("ext uint", b)
+		n = v
+	}
+	if d.strictCanonical && b != canonicalExtUint(n) {
+		return 0, 0, d.fail(ErrNotCanonical, start)
 	}
+	return typ, n, nil
 }
 
 func (d *Decoder) GetFloat() (float64, error) {
-	b, err := d.readByte()
+	start := d.offset
+	n, err := d.getNumber(KindFloat)
 	if err != nil {
 		return 0, err
 	}
-	switch b {
-	case 0xca:
-		n, err := d.readFloat32()
-		if err != nil {
-			return 0, err
-		}
-		return float64(n), nil
-	case 0xcb:
-		n, err := d.readFloat64()
-		if err != nil {
-			return 0, err
-		}
-		return n, nil
-	default:
-		return invalid[float64]("float", b)
+	v, err := n.toFloat()
+	if err != nil {
+		return 0, d.fail(err, start)
 	}
+	return v, nil
 }
 
 func (d *Decoder) GetInt() (int64, error) {
-	b, err := d.readByte()
+	start := d.offset
+	n, err := d.getNumber(KindInt)
 	if err != nil {
 		return 0, err
 	}
-	if b&0x80 == 0 {
-		// positive
-		return int64(b), nil
-	}
-	if b&0xe0 == 0xe0 {
-		// negative
-		return int64(b) - 256, nil
-	}
-	switch b {
-	case 0xd0:
-		n, err := d.readInt8()
-		if err != nil {
-			return 0, err
-		}
-		return int64(n), nil
-	case 0xd1:
-		n, err := d.readInt16()
-		if err != nil {
-			return 0, err
-		}
-		return int64(n), nil
-	case 0xd2:
-		n, err := d.readInt32()
-		if err != nil {
-			return 0, err
-		}
-		return int64(n), nil
-	case 0xd3:
-		n, err := d.readInt64()
-		if err != nil {
-			return 0, err
-		}
-		return n, nil
-	default:
-		return invalid[int64]("int", b)
+	v, err := n.toInt()
+	if err != nil {
+		return 0, d.fail(err, start)
 	}
+	return v, nil
 }
 
 func (d *Decoder) GetMapLength() (uint32, error) {
-	b, err := d.readByte()
+	return d.getLength(KindMap)
+}
+
+func (d *Decoder) GetRaw() (Raw, error) {
+	raw, err := d.consume(d.Skip)
 	if err != nil {
-		return 0, err
-	}
-	if b&0xf0 == 0x80 {
-		return uint32(b & 0x0f), nil
-	}
-	switch b {
-	case 0xde:
-		n, err := d.readUint16()
-		if err != nil {
-			return 0, err
-		}
-		return uint32(n), nil
-	case 0xdf:
-		n, err := d.readUint32()
-		if err != nil {
-			return 0, err
-		}
-		return n, nil
-	default:
-		return invalid[uint32]("map length", b)
+		return nil, err
 	}
+	return Raw(raw), nil
 }
 
 func (d *Decoder) GetString() (string, error) {
-	b, err := d.readByte()
+	n, err := d.getLength(KindString)
 	if err != nil {
 		return "", err
 	}
-	var size int
-	if b&0xe0 == 0xa0 {
-		size = int(b & 0x1f)
-	} else {
-		switch b {
-		case 0xd9:
-			n, err := d.readUint8()
-			if err != nil {
-				return "", err
-			}
-			size = int(n)
-		case 0xda:
-			n, err := d.readUint16()
-			if err != nil {
-				return "", err
-			}
-			size = int(n)
-		case 0xdb:
-			n, err := d.readUint32()
-			if err != nil {
-				return "", err
-			}
-			size = int(n)
-		default:
-			return invalid[string]("string", b)
-		}
-	}
-	bytes, err := d.readBytes(size)
+	return d.readString(int(n))
+}
+
+// Returns a string value without copying its bytes. The string shares
+// memory with the input just as the slice returned by GetBinaryView
+// does, and is subject to the same rules: it must not be used after
+// that memory is modified or reused.
+func (d *Decoder) GetStringView() (string, error) {
+	n, err := d.getLength(KindString)
 	if err != nil {
 		return "", err
 	}
-	return string(bytes), nil
+	bytes, err := d.viewBytes(int(n))
+	if err != nil || len(bytes) == 0 {
+		return "", err
+	}
+	return unsafe.String(unsafe.SliceData(bytes), len(bytes)), nil
+}
+
+// Returns a reader over the bytes of a string value along with its
+// length. See GetBinaryReader.
+func (d *Decoder) GetStringReader() (io.Reader, int64, error) {
+	n, err := d.getLength(KindString)
+	if err != nil {
+		return nil, 0, err
+	}
+	return d.payloadReader(int64(n))
 }
 
 func (d *Decoder) GetTime() (time.Time, error) {
-	b, err := d.readByte()
+	start := d.offset
+	b, err := d.readLead()
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -284,9 +340,9 @@ func (d *Decoder) GetTime() (time.Time, error) {
 			return time.Time{}, err
 		}
 		if t != 255 {
-			return invalid[time.Time]("timestamp32 type", t)
+			return invalid[time.Time](d, KindTimestamp, t)
 		}
-		n, err := d.readInt32()
+		n, err := d.readUint32()
 		if err != nil {
 			return time.Time{}, err
 		}
@@ -300,13 +356,13 @@ func (d *Decoder) GetTime() (time.Time, error) {
 			return time.Time{}, err
 		}
 		if t != 255 {
-			return invalid[time.Time]("timestamp64 type", t)
+			return invalid[time.Time](d, KindTimestamp, t)
 		}
 		n, err := d.readUint64()
 		if err != nil {
 			return time.Time{}, err
 		}
-		sec = int64(n & 0x7fffffff)
+		sec = int64(n & mask34)
 		nsec = int64(n >> 34)
 
 	case 0xc7:
@@ -316,14 +372,14 @@ func (d *Decoder) GetTime() (time.Time, error) {
 			return time.Time{}, err
 		}
 		if n != 12 {
-			return invalid[time.Time]("timestamp95 length", n)
+			return invalid[time.Time](d, KindTimestamp, n)
 		}
 		t, err := d.readByte()
 		if err != nil {
 			return time.Time{}, err
 		}
 		if t != 255 {
-			return invalid[time.Time]("timestamp95 type", t)
+			return invalid[time.Time](d, KindTimestamp, t)
 		}
 		n1, err := d.readUint32()
 		if err != nil {
@@ -337,172 +393,816 @@ func (d *Decoder) GetTime() (time.Time, error) {
 		nsec = int64(n1)
 
 	default:
-		return invalid[time.Time]("timestamp extension", b)
+		return invalid[time.Time](d, KindTimestamp, b)
+	}
+	// The spec limits the nanoseconds to less than a second, which
+	// time.Unix would otherwise quietly carry into the seconds
+	if nsec > 999999999 {
+		err := fmt.Errorf("timestamp nanoseconds (%d) out of range", nsec)
+		return time.Time{}, d.fail(err, start)
+	}
+	if d.strictCanonical && b != canonicalTime(sec, nsec) {
+		return time.Time{}, d.fail(ErrNotCanonical, start)
 	}
 	return time.Unix(sec, nsec).UTC(), nil
 }
 
 func (d *Decoder) GetUint() (uint64, error) {
-	b, err := d.readByte()
+	start := d.offset
+	n, err := d.getNumber(KindUint)
 	if err != nil {
 		return 0, err
 	}
-	if b&0x80 == 0 {
-		return uint64(b), nil
+	v, err := n.toUint()
+	if err != nil {
+		return 0, d.fail(err, start)
 	}
-	switch b {
-	case 0xcc:
-		n, err := d.readUint8()
+	return v, nil
+}
+
+func (d *Decoder) GetValue() (Value, error) {
+	k, err := d.PeekKind()
+	if err != nil {
+		return Value{}, err
+	}
+	switch k {
+	case KindNil:
+		d.readLead()
+		return NilValue(), nil
+	case KindBool:
+		v, err := d.GetBool()
+		return BoolValue(v), err
+	case KindInt:
+		v, err := d.GetInt()
+		return IntValue(v), err
+	case KindUint:
+		v, err := d.GetUint()
+		return UintValue(v), err
+	case KindFloat:
+		v, err := d.GetFloat()
+		return FloatValue(v), err
+	case KindString:
+		v, err := d.GetString()
+		return StringValue(v), err
+	case KindBinary:
+		v, err := d.GetBinary()
+		return BinaryValue(v), err
+	case KindArray:
+		n, err := d.GetArrayLength()
 		if err != nil {
-			return 0, err
+			return Value{}, err
 		}
-		return uint64(n), nil
-	case 0xcd:
-		n, err := d.readUint16()
-		if err != nil {
-			return 0, err
+		items := make([]Value, 0, d.ArrayCapacity(n))
+		for i := range int(n) {
+			d.PushIndex(i)
+			item, err := d.GetValue()
+			d.Pop()
+			if err != nil {
+				return Value{}, err
+			}
+			items = append(items, item)
 		}
-		return uint64(n), nil
-	case 0xce:
-		n, err := d.readUint32()
+		return ArrayValue(items...), nil
+	case KindMap:
+		n, err := d.GetMapLength()
 		if err != nil {
-			return 0, err
+			return Value{}, err
 		}
-		return uint64(n), nil
-	case 0xcf:
-		n, err := d.readUint64()
-		if err != nil {
-			return 0, err
+		entries := make([]Entry, 0, d.MapCapacity(n))
+		var prev []byte
+		for range n {
+			key, curr, err := decodeKey(d, prev, d.GetValue)
+			if err != nil {
+				return Value{}, err
+			}
+			prev = curr
+			d.PushKeyValue(key)
+			value, err := d.GetValue()
+			d.Pop()
+			if err != nil {
+				return Value{}, err
+			}
+			entries = append(entries, Entry{key, value})
 		}
-		return n, nil
+		return MapValue(entries...), nil
+	case KindExt:
+		typ, data, err := d.GetExt()
+		return ExtValue(typ, data), err
 	default:
-		return invalid[uint64]("uint", b)
+		v, err := d.GetTime()
+		return TimeValue(v), err
 	}
 }
 
+func (d *Decoder) Offset() int {
+	return d.offset
+}
+
+func (d *Decoder) Path() string {
+	s := "$"
+	for _, seg := range d.path {
+		s += seg.String()
+	}
+	return s
+}
+
+func (d *Decoder) Pop() {
+	if len(d.path) > 0 {
+		d.path = d.path[:len(d.path)-1]
+	}
+}
+
+func (d *Decoder) PushIndex(i int) {
+	d.path = append(d.path, segment{index: i})
+}
+
+func (d *Decoder) PushKey(key string) {
+	d.path = append(d.path, segment{kind: KindString, key: key, isKey: true})
+}
+
+// Pushes a map key of any kind. Scalar keys are kept as decoded and
+// only formatted if the path is.
+func (d *Decoder) PushKeyValue(key Value) {
+	s := segment{kind: key.kind, isKey: true}
+	switch key.kind {
+	case KindBool:
+		if key.b {
+			s.bits = 1
+		}
+	case KindInt:
+		s.bits = uint64(key.i)
+	case KindUint:
+		s.bits = key.u
+	case KindFloat:
+		s.bits = math.Float64bits(key.f)
+	default:
+		s.kind = KindString
+		s.key = key.pathKey()
+	}
+	d.path = append(d.path, s)
+}
+
 func (d *Decoder) IfNil() (bool, error) {
 	isNil, err := d.IsNil()
 	if err != nil {
 		return false, err
 	}
 	if isNil {
-		d.readByte()
+		d.readLead()
 	}
 	return isNil, err
 }
 
 func (d *Decoder) IsNil() (bool, error) {
-	b, err := d.peekByte()
+	b, err := d.peekLead()
 	if err != nil {
 		return false, err
 	}
 	return b == 0xc0, nil
 }
 
+func (d *Decoder) PeekKind() (Kind, error) {
+	b, err := d.peekLead()
+	if err != nil {
+		return 0, err
+	}
+	f := formats[b]
+	if !f.valid {
+		return unknown[Kind](d, b, d.offset)
+	}
+	k := f.kind
+	// Timestamps are ext values with type -1 so the type byte
+	// has to be inspected as well
+	var at int
+	switch b {
+	case 0xd6, 0xd7:
+		at = 1
+	case 0xc7:
+		at = 2
+	default:
+		return k, nil
+	}
+	bytes, err := d.peekBytes(at + 1)
+	if err != nil {
+		return 0, err
+	}
+	if int8(bytes[at]) == -1 {
+		return KindTimestamp, nil
+	}
+	return k, nil
+}
+
+func (d *Decoder) Skip() error {
+	if d.strictCanonical {
+		return d.skipCanonical()
+	}
+	n, err := d.skipValue()
+	if err != nil {
+		return err
+	}
+	// Each value can add nested values to be skipped so keep going
+	// until there are none left
+	for ; n > 0; n-- {
+		items, err := d.skipValue()
+		if err != nil {
+			return err
+		}
+		n += items
+	}
+	return nil
+}
+
+// Skips a value in strict canonical mode, checking its headers and
+// numbers as the Get methods would and the order of any map keys
+func (d *Decoder) skipCanonical() error {
+	k, err := d.PeekKind()
+	if err != nil {
+		return err
+	}
+	switch k {
+	case KindNil, KindBool:
+		_, err = d.readLead()
+	case KindInt, KindUint, KindFloat:
+		_, err = d.getNumber(k)
+	case KindTimestamp:
+		_, err = d.GetTime()
+	case KindArray:
+		n, err := d.getLength(k)
+		if err != nil {
+			return err
+		}
+		for range n {
+			if err := d.skipCanonical(); err != nil {
+				return err
+			}
+		}
+	case KindMap:
+		n, err := d.getLength(k)
+		if err != nil {
+			return err
+		}
+		var prev []byte
+		for range n {
+			_, prev, err = decodeKey(d, prev, func() (struct{}, error) {
+				return struct{}{}, d.skipCanonical()
+			})
+			if err != nil {
+				return err
+			}
+			if err := d.skipCanonical(); err != nil {
+				return err
+			}
+		}
+	default:
+		var n uint32
+		n, err = d.getLength(k)
+		if err != nil {
+			return err
+		}
+		size := int(n)
+		if k == KindExt {
+			// The type byte
+			size++
+		}
+		err = d.skipBytes(size)
+	}
+	return err
+}
+
+// Runs f and returns the bytes it consumed. For a stream decoder
+// these are captured as they are read and returned as a copy.
+func (d *Decoder) consume(f func() error) ([]byte, error) {
+	if d.r == nil {
+		start := d.bytes
+		if err := f(); err != nil {
+			return nil, err
+		}
+		return start[:len(start)-len(d.bytes)], nil
+	}
+	mark := len(d.captured)
+	d.capturing++
+	err := f()
+	d.capturing--
+	var bytes []byte
+	if err == nil {
+		bytes = slices.Clone(d.captured[mark:])
+	}
+	if d.capturing == 0 {
+		d.captured = d.captured[:0]
+	}
+	return bytes, err
+}
+
+// Tops up the buffer of a stream decoder until it holds at least n
+// bytes or the reader runs out
+func (d *Decoder) fill(n int) {
+	if d.r == nil {
+		return
+	}
+	if d.payload != nil {
+		// Whatever the caller didn't read of the last payload
+		p := d.payload
+		d.payload = nil
+		io.Copy(io.Discard, p)
+	}
+	if len(d.bytes) >= n {
+		return
+	}
+	if cap(d.buf) < n {
+		d.buf = make([]byte, max(n, streamBufferSize))
+	}
+	m := copy(d.buf[:cap(d.buf)], d.bytes)
+	d.bytes = d.buf[:m]
+	for len(d.bytes) < n && d.rerr == nil {
+		m, err := d.r.Read(d.bytes[len(d.bytes):cap(d.bytes)])
+		d.bytes = d.bytes[:len(d.bytes)+m]
+		d.rerr = err
+	}
+}
+
+// Reads a number in any of the int, uint or float formats. In strict
+// mode only the formats for the wanted kind are accepted, with positive
+// fixints counting as both ints and uints.
+func (d *Decoder) getNumber(want Kind) (number, error) {
+	start := d.offset
+	b, err := d.readLead()
+	if err != nil {
+		return number{}, err
+	}
+	k := formats[b].kind
+	ok := k == KindInt || k == KindUint || k == KindFloat
+	if ok && d.strictNumbers {
+		ok = k == want || (want == KindUint && b <= 0x7f)
+	}
+	if !ok {
+		return invalid[number](d, want, b)
+	}
+	n, err := d.readNumber(b)
+	if err != nil {
+		return number{}, err
+	}
+	if d.strictCanonical && !n.canonical(b) {
+		return number{}, d.fail(ErrNotCanonical, start)
+	}
+	return n, nil
+}
+
+// Reads the rest of a number with the given leading byte
+func (d *Decoder) readNumber(b byte) (number, error) {
+	if b&0x80 == 0 {
+		// positive fixint
+		return number{kind: KindInt, i: int64(b)}, nil
+	}
+	if b&0xe0 == 0xe0 {
+		// negative fixint
+		return number{kind: KindInt, i: int64(b) - 256}, nil
+	}
+	switch b {
+	case 0xca:
+		n, err := d.readFloat32()
+		return number{kind: KindFloat, f: float64(n)}, err
+	case 0xcb:
+		n, err := d.readFloat64()
+		return number{kind: KindFloat, f: n}, err
+	case 0xcc:
+		n, err := d.readUint8()
+		return number{kind: KindUint, u: uint64(n)}, err
+	case 0xcd:
+		n, err := d.readUint16()
+		return number{kind: KindUint, u: uint64(n)}, err
+	case 0xce:
+		n, err := d.readUint32()
+		return number{kind: KindUint, u: uint64(n)}, err
+	case 0xcf:
+		n, err := d.readUint64()
+		return number{kind: KindUint, u: n}, err
+	case 0xd0:
+		n, err := d.readInt8()
+		return number{kind: KindInt, i: int64(n)}, err
+	case 0xd1:
+		n, err := d.readInt16()
+		return number{kind: KindInt, i: int64(n)}, err
+	case 0xd2:
+		n, err := d.readInt32()
+		return number{kind: KindInt, i: int64(n)}, err
+	default:
+		n, err := d.readInt64()
+		return number{kind: KindInt, i: n}, err
+	}
+}
+
+// Reads the leading byte and length of a string, binary, ext, array
+// or map value
+func (d *Decoder) getLength(k Kind) (uint32, error) {
+	start := d.offset
+	b, err := d.readLead()
+	if err != nil {
+		return 0, err
+	}
+	f := formats[b]
+	if !f.valid || f.kind != k {
+		return invalid[uint32](d, k, b)
+	}
+	n := f.size
+	if f.length > 0 {
+		n, err = d.readLength(f.length)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if d.strictCanonical && b != canonicalLength(k, n) {
+		return 0, d.fail(ErrNotCanonical, start)
+	}
+	d.pending += f.items(n)
+	return n, nil
+}
+
+func (d *Decoder) payloadReader(n int64) (io.Reader, int64, error) {
+	if d.r == nil {
+		data, err := d.readBytes(int(n))
+		if err != nil {
+			return nil, 0, err
+		}
+		return bytes.NewReader(data), n, nil
+	}
+	d.payload = &payload{d: d, n: n}
+	return d.payload, n, nil
+}
+
 func (d *Decoder) peekByte() (byte, error) {
-	return peek(d.bytes, 1, func(bytes []byte) byte {
+	return peek(d, 1, func(bytes []byte) byte {
 		return bytes[0]
 	})
 }
 
+func (d *Decoder) peekBytes(n int) ([]byte, error) {
+	return peek(d, n, func(bytes []byte) []byte {
+		return bytes[0:n]
+	})
+}
+
+// Peeks at the first byte of a value (see readLead)
+func (d *Decoder) peekLead() (byte, error) {
+	if err := d.atEOF(); err != nil {
+		return 0, err
+	}
+	return d.peekByte()
+}
+
 func (d *Decoder) readByte() (byte, error) {
-	return read(&d.bytes, 1, func(bytes []byte) byte {
+	return read(d, 1, func(bytes []byte) byte {
 		return bytes[0]
 	})
 }
 
 func (d *Decoder) readBytes(n int) ([]byte, error) {
-	return read(&d.bytes, n, func(bytes []byte) []byte {
-		return bytes[0:n]
+	if d.r == nil {
+		return read(d, n, func(bytes []byte) []byte {
+			return bytes[0:n]
+		})
+	}
+	// The buffer of a stream decoder gets reused so the bytes have
+	// to be copied out of it
+	if n <= streamBufferSize {
+		return read(d, n, func(bytes []byte) []byte {
+			return slices.Clone(bytes[0:n])
+		})
+	}
+	var buf bytes.Buffer
+	if err := d.copyBytes(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Reads n bytes in place, leaving them in the input or buffer. A
+// stream decoder copies anything bigger than its usual buffer instead,
+// as it has arrived, rather than trusting n to size the buffer.
+func (d *Decoder) viewBytes(n int) ([]byte, error) {
+	if d.r != nil && n > streamBufferSize {
+		return d.readBytes(n)
+	}
+	return read(d, n, func(bytes []byte) []byte {
+		return bytes[0:n:n]
 	})
 }
 
+// Reads the first byte of a value, which is one fewer value owed to
+// the arrays and maps being decoded. For a stream decoder, running out
+// of input here when none are owed is the clean end of the stream and
+// is reported as io.EOF.
+func (d *Decoder) readLead() (byte, error) {
+	if err := d.atEOF(); err != nil {
+		return 0, err
+	}
+	b, err := d.readByte()
+	if err == nil && d.pending > 0 {
+		d.pending--
+	}
+	return b, err
+}
+
+func (d *Decoder) readLength(width int) (uint32, error) {
+	switch width {
+	case 1:
+		n, err := d.readUint8()
+		return uint32(n), err
+	case 2:
+		n, err := d.readUint16()
+		return uint32(n), err
+	default:
+		return d.readUint32()
+	}
+}
+
 func (d *Decoder) readFloat32() (float32, error) {
-	return read(&d.bytes, 4, func(bytes []byte) float32 {
+	return read(d, 4, func(bytes []byte) float32 {
 		u := binary.BigEndian.Uint32(bytes)
 		return math.Float32frombits(u)
 	})
 }
 
 func (d *Decoder) readFloat64() (float64, error) {
-	return read(&d.bytes, 8, func(bytes []byte) float64 {
+	return read(d, 8, func(bytes []byte) float64 {
 		u := binary.BigEndian.Uint64(bytes)
 		return math.Float64frombits(u)
 	})
 }
 
 func (d *Decoder) readInt8() (int8, error) {
-	return read(&d.bytes, 1, func(bytes []byte) int8 {
+	return read(d, 1, func(bytes []byte) int8 {
 		return int8(bytes[0])
 	})
 }
 
 func (d *Decoder) readInt16() (int16, error) {
-	return read(&d.bytes, 2, func(bytes []byte) int16 {
+	return read(d, 2, func(bytes []byte) int16 {
 		return int16(binary.BigEndian.Uint16(bytes))
 	})
 }
 
 func (d *Decoder) readInt32() (int32, error) {
-	return read(&d.bytes, 4, func(bytes []byte) int32 {
+	return read(d, 4, func(bytes []byte) int32 {
 		return int32(binary.BigEndian.Uint32(bytes))
 	})
 }
 
 func (d *Decoder) readInt64() (int64, error) {
-	return read(&d.bytes, 8, func(bytes []byte) int64 {
+	return read(d, 8, func(bytes []byte) int64 {
 		return int64(binary.BigEndian.Uint64(bytes))
 	})
 }
 
 func (d *Decoder) readUint8() (uint8, error) {
-	return read(&d.bytes, 1, func(bytes []byte) uint8 {
+	return read(d, 1, func(bytes []byte) uint8 {
 		return bytes[0]
 	})
 }
 
 func (d *Decoder) readUint16() (uint16, error) {
-	return read(&d.bytes, 2, func(bytes []byte) uint16 {
+	return read(d, 2, func(bytes []byte) uint16 {
 		return binary.BigEndian.Uint16(bytes)
 	})
 }
 
 func (d *Decoder) readUint32() (uint32, error) {
-	return read(&d.bytes, 4, func(bytes []byte) uint32 {
+	return read(d, 4, func(bytes []byte) uint32 {
 		return binary.BigEndian.Uint32(bytes)
 	})
 }
 
 func (d *Decoder) readUint64() (uint64, error) {
-	return read(&d.bytes, 8, func(bytes []byte) uint64 {
+	return read(d, 8, func(bytes []byte) uint64 {
 		return binary.BigEndian.Uint64(bytes)
 	})
 }
 
-func peek[T any](bytes []byte, size int, f func([]byte) T) (T, error) {
-	excess := size - len(bytes)
-	if excess > 0 {
-		return fail[T]("trying to read %d bytes beyond end of buffer (%d bytes)", excess, len(bytes))
+func (d *Decoder) readString(n int) (string, error) {
+	if d.r != nil && n > streamBufferSize {
+		bytes, err := d.readBytes(n)
+		return string(bytes), err
+	}
+	return read(d, n, func(bytes []byte) string {
+		return string(bytes[0:n])
+	})
+}
+
+func (d *Decoder) skipBytes(n int) error {
+	if d.r == nil || n <= streamBufferSize {
+		_, err := read(d, n, func(bytes []byte) struct{} {
+			return struct{}{}
+		})
+		return err
+	}
+	return d.copyBytes(io.Discard, n)
+}
+
+// Skips the leading byte, length field and payload of a single value,
+// returning the number of nested values that follow it (which are then
+// owed)
+func (d *Decoder) skipValue() (int, error) {
+	b, err := d.readLead()
+	if err != nil {
+		return 0, err
+	}
+	f := formats[b]
+	if !f.valid {
+		return unknown[int](d, b, d.offset-1)
+	}
+	n := f.size
+	if f.length > 0 {
+		n, err = d.readLength(f.length)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := d.skipBytes(f.skip(n)); err != nil {
+		return 0, err
+	}
+	items := f.items(n)
+	d.pending += items
+	return items, nil
+}
+
+// Checks for the clean end of a stream, between top-level values
+func (d *Decoder) atEOF() error {
+	if d.r == nil || d.pending > 0 {
+		return nil
+	}
+	d.fill(1)
+	if len(d.bytes) == 0 && d.rerr == io.EOF {
+		return io.EOF
+	}
+	return nil
+}
+
+// Copies n bytes from a stream decoder to w, a buffer at a time
+func (d *Decoder) copyBytes(w io.Writer, n int) error {
+	for done := 0; done < n; {
+		d.fill(1)
+		if len(d.bytes) == 0 {
+			return d.readError(n, done)
+		}
+		m := min(n-done, len(d.bytes))
+		w.Write(d.bytes[:m])
+		if d.capturing > 0 {
+			d.captured.Write(d.bytes[:m])
+		}
+		d.bytes = d.bytes[m:]
+		d.offset += m
+		done += m
+	}
+	return nil
+}
+
+// Wraps an error with where it happened
+func (d *Decoder) fail(err error, offset int) error {
+	return &DecodeError{Path: d.Path(), Offset: offset, Err: err}
+}
+
+// Reports running out of input while needing n more bytes
+func (d *Decoder) readError(need, have int) error {
+	if d.rerr != nil && d.rerr != io.EOF {
+		return d.fail(d.rerr, d.offset)
+	}
+	return d.fail(&ShortBufferError{Need: need, Have: have}, d.offset)
+}
+
+// Reads a map key with get. In strict canonical mode its encoded bytes
+// are returned as well and must come after the bytes of the previous
+// key.
+func decodeKey[K any](d *Decoder, prev []byte, get func() (K, error)) (K, []byte, error) {
+	if !d.strictCanonical {
+		key, err := get()
+		return key, nil, err
+	}
+	start := d.offset
+	var key K
+	curr, err := d.consume(func() (err error) {
+		key, err = get()
+		return err
+	})
+	if err != nil {
+		return key, nil, err
+	}
+	if prev != nil && bytes.Compare(prev, curr) >= 0 {
+		return key, nil, d.fail(ErrNotCanonical, start)
+	}
+	return key, curr, nil
+}
+
+func peek[T any](d *Decoder, size int, f func([]byte) T) (T, error) {
+	d.fill(size)
+	if size > len(d.bytes) {
+		return *new(T), d.readError(size, len(d.bytes))
 	}
-	return f(bytes), nil
+	return f(d.bytes), nil
 }
 
-func read[T any](bytes *[]byte, size int, f func([]byte) T) (T, error) {
-	value, err := peek(*bytes, size, f)
+func read[T any](d *Decoder, size int, f func([]byte) T) (T, error) {
+	value, err := peek(d, size, f)
 	if err == nil {
-		*bytes = (*bytes)[size:]
+		if d.capturing > 0 {
+			d.captured.Write(d.bytes[:size])
+		}
+		d.bytes = d.bytes[size:]
+		d.offset += size
 	}
 	return value, err
 }
 
-func fail[T any](mesg string, args ...any) (T, error) {
-	return *new(T), fmt.Errorf(mesg, args...)
+// Accumulates the bytes read by a stream decoder (see consume)
+type capture []byte
+
+func (c *capture) Write(p []byte) (int, error) {
+	*c = append(*c, p...)
+	return len(p), nil
 }
 
-func invalid[T any](what string, b byte) (T, error) {
-	return fail[T]("invalid byte for %s (%#02x)", what, b)
+// Reads the payload of a value directly from a stream decoder
+type payload struct {
+	d *Decoder
+	n int64
 }
 
-func invalid2[T, U any](what string, b byte) (T, U, error) {
-	return *new(T), *new(U), fmt.Errorf("invalid byte for %s (%#02x)", what, b)
+func (p *payload) Read(b []byte) (int, error) {
+	if p.n <= 0 {
+		return 0, io.EOF
+	}
+	d := p.d
+	if int64(len(b)) > p.n {
+		b = b[:p.n]
+	}
+	var m int
+	if len(d.bytes) > 0 {
+		m = copy(b, d.bytes)
+		d.bytes = d.bytes[m:]
+	} else if d.rerr != nil {
+		return 0, d.readError(int(p.n), 0)
+	} else {
+		m, d.rerr = d.r.Read(b)
+	}
+	if d.capturing > 0 {
+		d.captured.Write(b[:m])
+	}
+	d.offset += m
+	p.n -= int64(m)
+	return m, nil
+}
+
+// The byte just read is not valid for the expected kind
+func invalid[T any](d *Decoder, k Kind, b byte) (T, error) {
+	err := &TypeError{Expected: k, Got: b, Offset: d.offset - 1}
+	return *new(T), d.fail(err, err.Offset)
+}
+
+func invalid2[T, U any](d *Decoder, k Kind, b byte) (T, U, error) {
+	err := &TypeError{Expected: k, Got: b, Offset: d.offset - 1}
+	return *new(T), *new(U), d.fail(err, err.Offset)
+}
+
+// The byte at offset does not start any kind of value
+func unknown[T any](d *Decoder, b byte, offset int) (T, error) {
+	err := &FormatError{Got: b, Offset: offset}
+	return *new(T), d.fail(err, offset)
+}
+
+// An array index or map key in a decoder path. A key is a string or
+// the bits of a scalar of the given kind.
+type segment struct {
+	key   string
+	bits  uint64
+	kind  Kind
+	index int
+	isKey bool
+}
+
+func (s segment) String() string {
+	if !s.isKey {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	key := s.key
+	switch s.kind {
+	case KindBool:
+		key = fmt.Sprint(s.bits != 0)
+	case KindInt:
+		key = fmt.Sprint(int64(s.bits))
+	case KindUint:
+		key = fmt.Sprint(s.bits)
+	case KindFloat:
+		key = fmt.Sprint(math.Float64frombits(s.bits))
+	}
+	if isIdentifier(key) {
+		return "." + key
+	}
+	return fmt.Sprintf("[%q]", key)
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
 }
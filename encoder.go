@@ -1,18 +1,97 @@
 package msgpack
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
+	"slices"
+	"sync"
 	"time"
 )
 
-func NewEncoder() *Encoder {
-	return &Encoder{}
+func NewEncoder(opts ...EncoderOption) *Encoder {
+	e := &Encoder{}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// A stream encoder buffers its output and writes it to w whenever the
+// buffer fills up or Flush is called. A failed write is sticky: once
+// one fails all further output is discarded and the error is reported
+// by Err and Flush.
+func NewStreamEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	e := NewEncoder(opts...)
+	e.bytes = make([]byte, 0, streamBufferSize)
+	e.w = w
+	return e
+}
+
+const streamBufferSize = 4096
+
+var encoderPool = sync.Pool{
+	New: func() any {
+		return &Encoder{}
+	},
+}
+
+// Encoders holding more than this are not put back in the pool
+const maxPooledSize = 64 << 10
+
+// Returns an in-memory encoder from a pool, with its buffer left over
+// from whatever it last encoded. Pass it to ReleaseEncoder when done.
+func AcquireEncoder(opts ...EncoderOption) *Encoder {
+	e := encoderPool.Get().(*Encoder)
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Returns e to the pool. Neither e nor anything returned by its Bytes
+// method may be used afterwards.
+func ReleaseEncoder(e *Encoder) {
+	if cap(e.bytes) > maxPooledSize {
+		return
+	}
+	*e = Encoder{bytes: e.bytes[:0], epoch: e.epoch + 1}
+	encoderPool.Put(e)
+}
+
+type EncoderOption func(*Encoder)
+
+// Encode every value in exactly one way (see canonical.go) and sort
+// the keys of maps written by PutValue
+func WithCanonical() EncoderOption {
+	return func(e *Encoder) {
+		e.canonical = true
+	}
+}
+
+// Give every array and map started by BeginArray or BeginMap a 32 bit
+// length, which saves moving its contents along when it is ended.
+// Ignored in canonical mode.
+func WithFixedHeaders() EncoderOption {
+	return func(e *Encoder) {
+		e.fixedHeaders = true
+	}
 }
 
 type Encoder struct {
-	bytes []byte
+	bytes        []byte
+	canonical    bool
+	fixedHeaders bool
+	w            io.Writer
+	err          error
+	flushed      int
+	open         []int
+	marks        []int
+	// Counts the times open containers have been discarded, so their
+	// handles can tell
+	epoch int
 }
 
 func (e *Encoder) Bytes() []byte {
@@ -35,6 +114,106 @@ func (e *Encoder) AsString(maxLength int) string {
 
 func (e *Encoder) Clear() {
 	e.bytes = nil
+	e.open = nil
+	e.marks = nil
+	e.epoch++
+}
+
+// Discards everything encoded so far, like Clear, but keeps the buffer
+// to encode into again. Anything previously returned by Bytes gets
+// overwritten.
+func (e *Encoder) Reset() {
+	e.bytes = e.bytes[:0]
+	e.open = e.open[:0]
+	e.marks = e.marks[:0]
+	e.epoch++
+}
+
+// Makes room for at least n more bytes without reallocating
+func (e *Encoder) Grow(n int) {
+	e.bytes = slices.Grow(e.bytes, n)
+}
+
+// Returns the number of bytes encoded so far, including any a stream
+// encoder has already written
+func (e *Encoder) Len() int {
+	return e.flushed + len(e.bytes)
+}
+
+// Returns a mark which Rollback can later return to, discarding
+// anything encoded in between. A stream encoder holds back everything
+// encoded after a mark until it is rolled back to or released, so
+// marks should not be left live for longer than needed.
+func (e *Encoder) Mark() int {
+	mark := e.Len()
+	e.marks = append(e.marks, mark)
+	return mark
+}
+
+// Discards everything encoded since mark was taken and releases it,
+// along with any marks taken after it. A mark which has already been
+// released, including by ending a container it was taken in, can't be
+// rolled back to.
+func (e *Encoder) Rollback(mark int) error {
+	if _, live := slices.BinarySearch(e.marks, mark); !live {
+		if mark < e.flushed {
+			return fmt.Errorf("can't roll back to %d: %w", mark, ErrFlushed)
+		}
+		return fmt.Errorf("can't roll back to %d: mark has been released", mark)
+	}
+	if err := e.Truncate(mark); err != nil {
+		return err
+	}
+	e.ReleaseMark(mark)
+	return nil
+}
+
+// Keeps everything encoded since mark was taken and releases it, along
+// with any marks taken after it, so a stream encoder can write it
+func (e *Encoder) ReleaseMark(mark int) {
+	i, _ := slices.BinarySearch(e.marks, mark)
+	e.marks = e.marks[:i]
+	e.spill()
+}
+
+// Discards all but the first n bytes encoded, and any marks beyond
+// them. A stream encoder can't discard bytes it has already written,
+// so n must not be before the oldest live mark or any output since.
+func (e *Encoder) Truncate(n int) error {
+	if n < 0 || n > e.Len() {
+		return fmt.Errorf("can't truncate %d bytes to %d", e.Len(), n)
+	}
+	if n < e.flushed {
+		return fmt.Errorf("can't truncate to %d: %w", n, ErrFlushed)
+	}
+	if k := len(e.open); k > 0 && n < e.open[k-1]+containerHeaderSize {
+		return fmt.Errorf("can't truncate to %d: %w", n, ErrUnbalanced)
+	}
+	e.bytes = e.bytes[:n-e.flushed]
+	e.releaseMarks(n)
+	return nil
+}
+
+// Releases the marks beyond the first n bytes encoded
+func (e *Encoder) releaseMarks(n int) {
+	i, _ := slices.BinarySearch(e.marks, n+1)
+	e.marks = e.marks[:i]
+}
+
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Writes buffered output to the stream, apart from anything encoded
+// since the oldest live mark
+func (e *Encoder) Flush() error {
+	if len(e.open) > 0 {
+		return fmt.Errorf("can't flush with %d containers open: %w", len(e.open), ErrUnbalanced)
+	}
+	if e.w != nil {
+		e.flush()
+	}
+	return e.err
 }
 
 func (e *Encoder) PutArrayLength(v uint32) {
@@ -50,23 +229,25 @@ func (e *Encoder) PutArrayLength(v uint32) {
 }
 
 func (e *Encoder) PutBinary(v []byte) error {
-	n := len(v)
-	if n <= mask8 {
-		e.writeByte(0xc4)
-		e.writeUint8(uint8(n))
-	} else if n <= mask16 {
-		e.writeByte(0xc5)
-		e.writeUint16(uint16(n))
-	} else if n <= mask32 {
-		e.writeByte(0xc6)
-		e.writeUint32(uint32(n))
-	} else {
-		return fmt.Errorf("byte slice (%d bytes) is too long to encode", n)
+	if err := e.putBinaryLength(int64(len(v))); err != nil {
+		return err
 	}
 	e.writeBytes(v)
 	return nil
 }
 
+// Writes a binary value of n bytes copied from r, without holding the
+// whole payload in memory when e is a stream encoder. If r runs out
+// early the value is rolled back, unless part of it has already been
+// written to the stream in which case the error becomes sticky.
+func (e *Encoder) PutBinaryFrom(r io.Reader, n int64) error {
+	start := e.Len()
+	if err := e.putBinaryLength(n); err != nil {
+		return err
+	}
+	return e.copyFrom(r, n, start)
+}
+
 func (e *Encoder) PutBool(v bool) {
 	if !v {
 		e.writeByte(0xc2)
@@ -79,6 +260,38 @@ func (e *Encoder) PutBytes(v []byte) {
 	e.writeBytes(v)
 }
 
+func (e *Encoder) PutExt(typ int8, v []byte) error {
+	n := len(v)
+	switch n {
+	case 1:
+		e.writeByte(0xd4)
+	case 2:
+		e.writeByte(0xd5)
+	case 4:
+		e.writeByte(0xd6)
+	case 8:
+		e.writeByte(0xd7)
+	case 16:
+		e.writeByte(0xd8)
+	default:
+		if n <= mask8 {
+			e.writeByte(0xc7)
+			e.writeUint8(uint8(n))
+		} else if n <= mask16 {
+			e.writeByte(0xc8)
+			e.writeUint16(uint16(n))
+		} else if n <= mask32 {
+			e.writeByte(0xc9)
+			e.writeUint32(uint32(n))
+		} else {
+			return fmt.Errorf("ext data (%d bytes) is %w", n, ErrTooLong)
+		}
+	}
+	e.writeInt8(typ)
+	e.writeBytes(v)
+	return nil
+}
+
 func (e *Encoder) PutExtUint(typ uint8, v uint64) {
 	if v <= mask8 {
 		e.writeByte(0xd4)
@@ -100,43 +313,42 @@ func (e *Encoder) PutExtUint(typ uint8, v uint64) {
 }
 
 func (e *Encoder) PutFloat(v float64) {
-	// Try to work out if encoding a single-precision IEEE754 value
-	// is acceptable
-	ieee754 := math.Float64bits(v)
-
-	// ...check if the exponent is inside the range for single-precision
-	biasedExp := (ieee754 >> 52) & 0x7ff
-	unbiasedExp := int(biasedExp - 1023)
-	if unbiasedExp < -128 || unbiasedExp > 127 {
-		// nope!
-		e.PutFloat64(v)
+	if e.canonical {
+		e.putCanonicalFloat(v)
 		return
 	}
 
-	// ...check if the least significant bits of the mantissa are zero
-	leastSignficantBits := ieee754 & ((1 << 29) - 1)
-	if leastSignficantBits != 0 {
-		// nope!
+	// Single precision is enough when it holds the value exactly. A
+	// NaN never compares equal so keeps its payload in double precision.
+	if float64(float32(v)) == v {
+		e.PutFloat32(float32(v))
+	} else {
 		e.PutFloat64(v)
-		return
 	}
-
-	// ...it looks ok to only encode a single-precision (32 bit) version
-	e.PutFloat32(float32(v))
 }
 
 func (e *Encoder) PutFloat32(v float32) {
+	if e.canonical {
+		e.putCanonicalFloat(float64(v))
+		return
+	}
 	e.writeByte(0xca)
 	e.writeFloat32(v)
 }
 
 func (e *Encoder) PutFloat64(v float64) {
+	if e.canonical {
+		e.putCanonicalFloat(v)
+		return
+	}
 	e.writeByte(0xcb)
 	e.writeFloat64(v)
 }
 
 func (e *Encoder) PutInt(v int64) {
-	if v >= intFixMin && v <= intFixMax {
+	if e.canonical && v >= 0 {
+		e.PutUint(uint64(v))
+	} else if v >= intFixMin && v <= intFixMax {
 		e.writeInt8(int8(v))
 	} else if v >= int8Min && v <= int8Max {
 		e.writeByte(0xd0)
@@ -169,27 +381,42 @@ func (e *Encoder) PutNil() {
 	e.writeByte(0xc0)
 }
 
-func (e *Encoder) PutString(v string) error {
-	u := []byte(v)
-	n := len(u)
-	if n <= mask5 {
-		e.writeByte(byte(0xa0 | n))
-	} else if n <= mask8 {
-		e.writeByte(0xd9)
-		e.writeUint8(uint8(n))
-	} else if n <= mask16 {
-		e.writeByte(0xda)
-		e.writeUint16(uint16(n))
-	} else if n <= mask32 {
-		e.writeByte(0xdb)
-		e.writeUint32(uint32(n))
+// Writes raw bytes which must hold exactly one value. A canonical
+// encoder also requires the value to be canonically encoded.
+func (e *Encoder) PutRaw(v Raw) error {
+	var d *Decoder
+	if e.canonical {
+		d = NewDecoder(v, WithStrictCanonical())
 	} else {
-		return fmt.Errorf("string (%d bytes) is too long to encode", n)
+		d = NewDecoder(v)
+	}
+	if err := d.Skip(); err != nil {
+		return fmt.Errorf("raw bytes are not a valid value: %w", err)
+	}
+	if !d.IsEmpty() {
+		return fmt.Errorf("raw bytes have %d bytes after the value", d.Length())
+	}
+	e.writeBytes(v)
+	return nil
+}
+
+func (e *Encoder) PutString(v string) error {
+	if err := e.putStringLength(int64(len(v))); err != nil {
+		return err
 	}
-	e.writeBytes(u)
+	e.writeString(v)
 	return nil
 }
 
+// Writes a string value of n bytes copied from r. See PutBinaryFrom.
+func (e *Encoder) PutStringFrom(r io.Reader, n int64) error {
+	start := e.Len()
+	if err := e.putStringLength(n); err != nil {
+		return err
+	}
+	return e.copyFrom(r, n, start)
+}
+
 func (e *Encoder) PutTime(v time.Time) {
 	sec := v.Unix()
 	nsec := v.Nanosecond()
@@ -232,12 +459,253 @@ func (e *Encoder) PutUint(v uint64) {
 	}
 }
 
+func (e *Encoder) PutValue(v Value) error {
+	switch v.kind {
+	case KindNil:
+		e.PutNil()
+	case KindBool:
+		e.PutBool(v.b)
+	case KindInt:
+		e.PutInt(v.i)
+	case KindUint:
+		e.PutUint(v.u)
+	case KindFloat:
+		e.PutFloat(v.f)
+	case KindString:
+		return e.PutString(v.s)
+	case KindBinary:
+		return e.PutBinary(v.data)
+	case KindArray:
+		n := len(v.items)
+		if n > mask32 {
+			return fmt.Errorf("array (%d items) is %w", n, ErrTooLong)
+		}
+		e.PutArrayLength(uint32(n))
+		for _, item := range v.items {
+			if err := e.PutValue(item); err != nil {
+				return err
+			}
+		}
+	case KindMap:
+		return e.putMap(len(v.entries),
+			func(e *Encoder, i int) error {
+				return e.PutValue(v.entries[i].Key)
+			},
+			func(e *Encoder, i int) error {
+				return e.PutValue(v.entries[i].Value)
+			},
+		)
+	case KindExt:
+		return e.PutExt(v.typ, v.data)
+	case KindTimestamp:
+		e.PutTime(v.t)
+	default:
+		return fmt.Errorf("can't encode value of kind %s", v.kind)
+	}
+	return nil
+}
+
+func (e *Encoder) putBinaryLength(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("byte slice has negative length %d", n)
+	}
+	if n <= mask8 {
+		e.writeByte(0xc4)
+		e.writeUint8(uint8(n))
+	} else if n <= mask16 {
+		e.writeByte(0xc5)
+		e.writeUint16(uint16(n))
+	} else if n <= mask32 {
+		e.writeByte(0xc6)
+		e.writeUint32(uint32(n))
+	} else {
+		return fmt.Errorf("byte slice (%d bytes) is %w", n, ErrTooLong)
+	}
+	return nil
+}
+
+func (e *Encoder) putStringLength(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("string has negative length %d", n)
+	}
+	if n <= mask5 {
+		e.writeByte(byte(0xa0 | n))
+	} else if n <= mask8 {
+		e.writeByte(0xd9)
+		e.writeUint8(uint8(n))
+	} else if n <= mask16 {
+		e.writeByte(0xda)
+		e.writeUint16(uint16(n))
+	} else if n <= mask32 {
+		e.writeByte(0xdb)
+		e.writeUint32(uint32(n))
+	} else {
+		return fmt.Errorf("string (%d bytes) is %w", n, ErrTooLong)
+	}
+	return nil
+}
+
+func (e *Encoder) putCanonicalFloat(v float64) {
+	if math.IsNaN(v) {
+		e.writeByte(0xca)
+		e.writeUint32(canonicalNaN)
+	} else if canonicalFloat(v) == 0xca {
+		e.writeByte(0xca)
+		e.writeFloat32(float32(v))
+	} else {
+		e.writeByte(0xcb)
+		e.writeFloat64(v)
+	}
+}
+
+// Writes a map of n entries using the key and value functions to
+// write each entry. In canonical mode the entries are sorted by the
+// encoded bytes of their keys.
+func (e *Encoder) putMap(n int, key, value func(*Encoder, int) error) error {
+	if n > mask32 {
+		return fmt.Errorf("map (%d entries) is %w", n, ErrTooLong)
+	}
+	if !e.canonical {
+		e.PutMapLength(uint32(n))
+		for i := range n {
+			if err := key(e, i); err != nil {
+				return err
+			}
+			if err := value(e, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	k := &Encoder{canonical: true}
+	ends := make([]int, n)
+	for i := range n {
+		if err := key(k, i); err != nil {
+			return err
+		}
+		ends[i] = len(k.bytes)
+	}
+	keys := make([][]byte, n)
+	for i := range n {
+		start := 0
+		if i > 0 {
+			start = ends[i-1]
+		}
+		keys[i] = k.bytes[start:ends[i]]
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return bytes.Compare(keys[a], keys[b])
+	})
+	for i := 1; i < n; i++ {
+		if bytes.Equal(keys[order[i-1]], keys[order[i]]) {
+			return fmt.Errorf("duplicate map key (%x)", keys[order[i]])
+		}
+	}
+
+	e.PutMapLength(uint32(n))
+	for _, i := range order {
+		e.writeBytes(keys[i])
+		if err := value(e, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copies the n byte payload of a value from r. If that fails the whole
+// value, which begins at start, is discarded, or if part of it has
+// already been written to the stream the error is made sticky. Taking
+// a mark instead would hold the payload back from the stream.
+func (e *Encoder) copyFrom(r io.Reader, n int64, start int) error {
+	_, err := io.CopyN(payloadWriter{e}, r, n)
+	if err == nil {
+		return nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if e.Truncate(start) != nil && e.err == nil {
+		e.err = err
+	}
+	return err
+}
+
+func (e *Encoder) flush() {
+	n := e.writable()
+	if n == 0 {
+		return
+	}
+	if e.err == nil {
+		_, e.err = e.w.Write(e.bytes[:n])
+	}
+	e.flushed += n
+	e.bytes = e.bytes[:copy(e.bytes, e.bytes[n:])]
+}
+
+// Returns how many buffered bytes can be written to the stream: those
+// before the outermost open container, whose header has yet to be
+// filled in, and before the oldest live mark, which may be rolled back
+// to
+func (e *Encoder) writable() int {
+	n := len(e.bytes)
+	if len(e.open) > 0 {
+		n = min(n, e.open[0]-e.flushed)
+	}
+	if len(e.marks) > 0 {
+		n = min(n, e.marks[0]-e.flushed)
+	}
+	return n
+}
+
+// Writes the buffered bytes to the stream once there are enough of them
+func (e *Encoder) spill() {
+	if e.w != nil && len(e.bytes) >= streamBufferSize {
+		e.flush()
+	}
+}
+
+// Reports whether output can go straight to the stream, which it can't
+// while a container is open or a mark is live
+func (e *Encoder) streaming() bool {
+	return e.w != nil && len(e.open) == 0 && len(e.marks) == 0
+}
+
 func (e *Encoder) writeByte(v byte) {
 	e.bytes = append(e.bytes, v)
+	e.spill()
 }
 
 func (e *Encoder) writeBytes(v []byte) {
+	if e.streaming() && len(v) >= streamBufferSize {
+		// Large payloads go straight to the stream rather than
+		// being copied into the buffer first
+		e.flush()
+		if e.err == nil {
+			_, e.err = e.w.Write(v)
+		}
+		e.flushed += len(v)
+		return
+	}
 	e.bytes = append(e.bytes, v...)
+	e.spill()
+}
+
+func (e *Encoder) writeString(v string) {
+	if e.streaming() && len(v) >= streamBufferSize {
+		e.flush()
+		if e.err == nil {
+			_, e.err = io.WriteString(e.w, v)
+		}
+		e.flushed += len(v)
+		return
+	}
+	e.bytes = append(e.bytes, v...)
+	e.spill()
 }
 
 func (e *Encoder) writeFloat32(v float32) {
@@ -282,22 +750,33 @@ func (e *Encoder) writeUint64(v uint64) {
 
 func (e *Encoder) put8(v uint8) {
 	e.bytes = append(e.bytes, v)
+	e.spill()
 }
 
 func (e *Encoder) put16(v uint16) {
-	b := make([]byte, 2)
-	binary.BigEndian.PutUint16(b, v)
-	e.bytes = append(e.bytes, b...)
+	e.bytes = binary.BigEndian.AppendUint16(e.bytes, v)
+	e.spill()
 }
 
 func (e *Encoder) put32(v uint32) {
-	b := make([]byte, 4)
-	binary.BigEndian.PutUint32(b, v)
-	e.bytes = append(e.bytes, b...)
+	e.bytes = binary.BigEndian.AppendUint32(e.bytes, v)
+	e.spill()
 }
 
 func (e *Encoder) put64(v uint64) {
-	b := make([]byte, 8)
-	binary.BigEndian.PutUint64(b, v)
-	e.bytes = append(e.bytes, b...)
+	e.bytes = binary.BigEndian.AppendUint64(e.bytes, v)
+	e.spill()
+}
+
+// Adapts an encoder so payload bytes can be copied into it
+type payloadWriter struct {
+	e *Encoder
+}
+
+func (w payloadWriter) Write(p []byte) (int, error) {
+	w.e.writeBytes(p)
+	if w.e.err != nil {
+		return 0, w.e.err
+	}
+	return len(p), nil
 }
@@ -0,0 +1,78 @@
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrFlushed      = errors.New("already written to stream")
+	ErrNotCanonical = errors.New("value is not canonically encoded")
+	ErrTooLong      = errors.New("too long to encode")
+	ErrUnbalanced   = errors.New("unbalanced container")
+)
+
+type DecodeError struct {
+	Path   string
+	Offset int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("at %s (offset %d): %v", e.Path, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+type FormatError struct {
+	Got    byte
+	Offset int
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("invalid byte (%#02x)", e.Got)
+}
+
+type OverflowError struct {
+	Value any
+	Type  string
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("value %v overflows %s", e.Value, e.Type)
+}
+
+type PrecisionError struct {
+	Value any
+	Type  string
+}
+
+func (e *PrecisionError) Error() string {
+	return fmt.Sprintf("value %v can't be represented exactly as %s", e.Value, e.Type)
+}
+
+type ShortBufferError struct {
+	Need int
+	Have int
+}
+
+func (e *ShortBufferError) Error() string {
+	return fmt.Sprintf("trying to read %d bytes beyond end of buffer (%d bytes)", e.Need-e.Have, e.Have)
+}
+
+func (e *ShortBufferError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+type TypeError struct {
+	Expected Kind
+	Got      byte
+	Offset   int
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("invalid byte for %s (%#02x)", e.Expected, e.Got)
+}
@@ -0,0 +1,92 @@
+package msgpack
+
+// A format describes how a value is laid out, as determined by its
+// leading byte: an optional big-endian length field, an optional ext
+// type byte and then the payload. For arrays and maps the "payload" is
+// the number of elements that follow.
+type format struct {
+	kind   Kind
+	valid  bool
+	length int    // width of the length field (0, 1, 2 or 4 bytes)
+	size   uint32 // payload size when there is no length field
+	typed  bool   // an ext type byte precedes the payload
+}
+
+var formats [256]format
+
+func init() {
+	set := func(lo, hi int, f format) {
+		f.valid = true
+		for b := lo; b <= hi; b++ {
+			formats[b] = f
+		}
+	}
+	fix := func(lo, hi int, kind Kind) {
+		for b := lo; b <= hi; b++ {
+			formats[b] = format{kind: kind, valid: true, size: uint32(b - lo)}
+		}
+	}
+
+	set(0x00, 0x7f, format{kind: KindInt})
+	fix(0x80, 0x8f, KindMap)
+	fix(0x90, 0x9f, KindArray)
+	fix(0xa0, 0xbf, KindString)
+	set(0xc0, 0xc0, format{kind: KindNil})
+	// 0xc1 is never used
+	set(0xc2, 0xc3, format{kind: KindBool})
+	set(0xc4, 0xc4, format{kind: KindBinary, length: 1})
+	set(0xc5, 0xc5, format{kind: KindBinary, length: 2})
+	set(0xc6, 0xc6, format{kind: KindBinary, length: 4})
+	set(0xc7, 0xc7, format{kind: KindExt, length: 1, typed: true})
+	set(0xc8, 0xc8, format{kind: KindExt, length: 2, typed: true})
+	set(0xc9, 0xc9, format{kind: KindExt, length: 4, typed: true})
+	set(0xca, 0xca, format{kind: KindFloat, size: 4})
+	set(0xcb, 0xcb, format{kind: KindFloat, size: 8})
+	set(0xcc, 0xcc, format{kind: KindUint, size: 1})
+	set(0xcd, 0xcd, format{kind: KindUint, size: 2})
+	set(0xce, 0xce, format{kind: KindUint, size: 4})
+	set(0xcf, 0xcf, format{kind: KindUint, size: 8})
+	set(0xd0, 0xd0, format{kind: KindInt, size: 1})
+	set(0xd1, 0xd1, format{kind: KindInt, size: 2})
+	set(0xd2, 0xd2, format{kind: KindInt, size: 4})
+	set(0xd3, 0xd3, format{kind: KindInt, size: 8})
+	set(0xd4, 0xd4, format{kind: KindExt, size: 1, typed: true})
+	set(0xd5, 0xd5, format{kind: KindExt, size: 2, typed: true})
+	set(0xd6, 0xd6, format{kind: KindExt, size: 4, typed: true})
+	set(0xd7, 0xd7, format{kind: KindExt, size: 8, typed: true})
+	set(0xd8, 0xd8, format{kind: KindExt, size: 16, typed: true})
+	set(0xd9, 0xd9, format{kind: KindString, length: 1})
+	set(0xda, 0xda, format{kind: KindString, length: 2})
+	set(0xdb, 0xdb, format{kind: KindString, length: 4})
+	set(0xdc, 0xdc, format{kind: KindArray, length: 2})
+	set(0xdd, 0xdd, format{kind: KindArray, length: 4})
+	set(0xde, 0xde, format{kind: KindMap, length: 2})
+	set(0xdf, 0xdf, format{kind: KindMap, length: 4})
+	set(0xe0, 0xff, format{kind: KindInt})
+}
+
+// The number of values nested directly inside a value with this
+// format and the given length
+func (f format) items(n uint32) int {
+	switch f.kind {
+	case KindArray:
+		return int(n)
+	case KindMap:
+		return 2 * int(n)
+	default:
+		return 0
+	}
+}
+
+// The number of bytes following the length field for a value with
+// this format and the given length
+func (f format) skip(n uint32) int {
+	switch f.kind {
+	case KindArray, KindMap:
+		return 0
+	}
+	if f.typed {
+		return int(n) + 1
+	}
+	return int(n)
+}
@@ -0,0 +1,244 @@
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// How the length of each frame is written ahead of its payload
+type FrameFormat int
+
+const (
+	// A 4 byte big-endian unsigned integer
+	FrameUint32 FrameFormat = iota
+	// An unsigned varint as written by binary.AppendUvarint
+	FrameVarint
+	// A msgpack bin 8, bin 16 or bin 32 header, so that the whole
+	// frame is itself a valid msgpack binary value
+	FrameBinary
+)
+
+const DefaultMaxFrameSize = 16 << 20
+
+var ErrFrameTooLong = errors.New("too long to frame")
+
+type FrameOption func(*frameConfig)
+
+// Use format f for frame lengths (the default is FrameUint32)
+func WithFrameFormat(f FrameFormat) FrameOption {
+	return func(c *frameConfig) {
+		c.format = f
+	}
+}
+
+// Refuse to write or read a payload longer than n bytes (the default
+// is DefaultMaxFrameSize)
+func WithMaxFrameSize(n int) FrameOption {
+	return func(c *frameConfig) {
+		c.maxSize = n
+	}
+}
+
+type frameConfig struct {
+	format  FrameFormat
+	maxSize int
+}
+
+func newFrameConfig(opts []FrameOption) frameConfig {
+	c := frameConfig{
+		format:  FrameUint32,
+		maxSize: DefaultMaxFrameSize,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+func NewFrameWriter(w io.Writer, opts ...FrameOption) *FrameWriter {
+	return &FrameWriter{
+		config: newFrameConfig(opts),
+		w:      w,
+	}
+}
+
+// A FrameWriter writes each payload preceded by its length
+type FrameWriter struct {
+	config frameConfig
+	w      io.Writer
+	header []byte
+}
+
+// Writes the bytes held by e as a single frame
+func (fw *FrameWriter) WriteEncoder(e *Encoder) error {
+	return fw.WriteFrame(e.Bytes())
+}
+
+func (fw *FrameWriter) WriteFrame(payload []byte) error {
+	n := len(payload)
+	if n > fw.config.maxSize || uint64(n) > mask32 {
+		return fmt.Errorf("payload (%d bytes) is %w", n, ErrFrameTooLong)
+	}
+	h := fw.header[:0]
+	switch fw.config.format {
+	case FrameUint32:
+		h = binary.BigEndian.AppendUint32(h, uint32(n))
+	case FrameVarint:
+		h = binary.AppendUvarint(h, uint64(n))
+	case FrameBinary:
+		if n <= mask8 {
+			h = append(h, 0xc4, uint8(n))
+		} else if n <= mask16 {
+			h = binary.BigEndian.AppendUint16(append(h, 0xc5), uint16(n))
+		} else {
+			h = binary.BigEndian.AppendUint32(append(h, 0xc6), uint32(n))
+		}
+	default:
+		return fmt.Errorf("unknown frame format %d", fw.config.format)
+	}
+	fw.header = h
+	if _, err := fw.w.Write(h); err != nil {
+		return err
+	}
+	_, err := fw.w.Write(payload)
+	return err
+}
+
+func NewFrameReader(r io.Reader, opts ...FrameOption) *FrameReader {
+	return &FrameReader{
+		config: newFrameConfig(opts),
+		r:      bufio.NewReader(r),
+	}
+}
+
+// A FrameReader reads frames written by a FrameWriter. Running out of
+// input between frames is reported as io.EOF; running out part way
+// through a frame is reported as io.ErrUnexpectedEOF.
+//
+// A frame longer than the maximum size is skipped and reported with
+// an error wrapping ErrFrameTooLong, after which the next frame can
+// be read as usual. A length too large to skip leaves no way to find
+// the next frame, so the error it gives is returned from then on.
+type FrameReader struct {
+	config frameConfig
+	r      *bufio.Reader
+	offset int
+	err    error
+}
+
+// Reads the next frame and returns a new decoder over its payload
+func (fr *FrameReader) Next(opts ...DecoderOption) (*Decoder, error) {
+	payload, err := fr.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoder(payload, opts...), nil
+}
+
+// Offset returns the number of bytes read so far
+func (fr *FrameReader) Offset() int {
+	return fr.offset
+}
+
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	if fr.err != nil {
+		return nil, fr.err
+	}
+	n, err := fr.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt64 {
+		fr.err = fmt.Errorf("frame length %d is out of range", n)
+		return nil, fr.err
+	}
+	if n > uint64(fr.config.maxSize) {
+		m, err := io.CopyN(io.Discard, fr.r, int64(n))
+		fr.offset += int(m)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		return nil, fmt.Errorf("frame (%d bytes) is %w", n, ErrFrameTooLong)
+	}
+	payload := make([]byte, n)
+	m, err := io.ReadFull(fr.r, payload)
+	fr.offset += m
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	return payload, nil
+}
+
+func (fr *FrameReader) readLength() (uint64, error) {
+	var n uint64
+	var err error
+	start := fr.offset
+	switch fr.config.format {
+	case FrameUint32:
+		n, err = fr.readUint(4)
+	case FrameVarint:
+		n, err = binary.ReadUvarint(countingReader{fr})
+	case FrameBinary:
+		var b byte
+		b, err = fr.readByte()
+		if err != nil {
+			break
+		}
+		switch b {
+		case 0xc4:
+			n, err = fr.readUint(1)
+		case 0xc5:
+			n, err = fr.readUint(2)
+		case 0xc6:
+			n, err = fr.readUint(4)
+		default:
+			return 0, &FormatError{Got: b, Offset: start}
+		}
+	default:
+		return 0, fmt.Errorf("unknown frame format %d", fr.config.format)
+	}
+	if err == io.EOF && fr.offset > start {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (fr *FrameReader) readByte() (byte, error) {
+	b, err := fr.r.ReadByte()
+	if err == nil {
+		fr.offset++
+	}
+	return b, err
+}
+
+func (fr *FrameReader) readUint(width int) (uint64, error) {
+	var n uint64
+	for range width {
+		b, err := fr.readByte()
+		if err != nil {
+			return 0, err
+		}
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+// Counts the bytes binary.ReadUvarint reads
+type countingReader struct {
+	fr *FrameReader
+}
+
+func (c countingReader) ReadByte() (byte, error) {
+	return c.fr.readByte()
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
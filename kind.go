@@ -0,0 +1,48 @@
+package msgpack
+
+import "fmt"
+
+type Kind int
+
+const (
+	KindNil Kind = iota
+	KindBool
+	KindInt
+	KindUint
+	KindFloat
+	KindString
+	KindBinary
+	KindArray
+	KindMap
+	KindExt
+	KindTimestamp
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNil:
+		return "nil"
+	case KindBool:
+		return "bool"
+	case KindInt:
+		return "int"
+	case KindUint:
+		return "uint"
+	case KindFloat:
+		return "float"
+	case KindString:
+		return "string"
+	case KindBinary:
+		return "binary"
+	case KindArray:
+		return "array"
+	case KindMap:
+		return "map"
+	case KindExt:
+		return "ext"
+	case KindTimestamp:
+		return "timestamp"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
@@ -0,0 +1,733 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A type which implements Marshaler encodes itself
+type Marshaler interface {
+	EncodeMsgpack(*Encoder) error
+}
+
+// A type which implements Unmarshaler decodes itself
+type Unmarshaler interface {
+	DecodeMsgpack(*Decoder) error
+}
+
+// Returns the encoding of v, walking it with reflection:
+//
+//   - bool, the integer and float types and string are encoded as the
+//     corresponding msgpack types
+//   - []byte and [N]byte are encoded as binary
+//   - other slices and arrays are encoded as arrays
+//   - maps are encoded as maps
+//   - structs are encoded as maps from field name to value (see below)
+//   - time.Time is encoded as a timestamp, Ext as an ext value, and
+//     Raw and Value as themselves
+//   - pointers and interfaces are encoded as the value they hold
+//   - nil pointers, interfaces, slices and maps are encoded as nil
+//
+// Types which implement Marshaler encode themselves.
+//
+// Only exported struct fields are encoded. The name of a field can be
+// changed with a tag such as `msgpack:"name"`, a field with the tag
+// `msgpack:"-"` is left out and `msgpack:",omitempty"` leaves a field
+// out when it holds the zero value. The fields of an embedded struct
+// without a tag are encoded as if they belonged to the outer struct,
+// unless the outer struct has a field of the same name.
+func Marshal(v any, opts ...EncoderOption) ([]byte, error) {
+	e := NewEncoder(opts...)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Decodes data, which must hold exactly one value, into what v points
+// to. It is the reverse of Marshal. Decoding nil sets the target to
+// its zero value; decoding into an interface{} stores the result of
+// Value.Any. Map keys which match no struct field are skipped.
+func Unmarshal(data []byte, v any, opts ...DecoderOption) error {
+	d := NewDecoder(data, opts...)
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if !d.IsEmpty() {
+		return d.fail(fmt.Errorf("%d bytes left over", d.Length()), d.offset)
+	}
+	return nil
+}
+
+// Encodes v as Marshal does
+func (e *Encoder) Encode(v any) error {
+	if v == nil {
+		e.PutNil()
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	return planFor(rv.Type()).encode(e, rv)
+}
+
+// Decodes the next value into what v points to, as Unmarshal does
+func (d *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("can't decode into %T: need a non-nil pointer", v)
+	}
+	return planFor(rv.Type().Elem()).decode(d, rv.Elem())
+}
+
+// A plan encodes and decodes one type. Plans are built the first time
+// a type is seen and then cached.
+type plan struct {
+	encode func(*Encoder, reflect.Value) error
+	decode func(*Decoder, reflect.Value) error
+}
+
+var (
+	plans      sync.Map // reflect.Type -> *plan
+	plansMutex sync.Mutex
+)
+
+func planFor(t reflect.Type) *plan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*plan)
+	}
+	plansMutex.Lock()
+	defer plansMutex.Unlock()
+	// Plans refer to each other so none are published until all
+	// those needed (including for recursive types) are complete
+	building := map[reflect.Type]*plan{}
+	p := buildPlan(t, building)
+	for t, p := range building {
+		plans.Store(t, p)
+	}
+	return p
+}
+
+var (
+	marshalerType   = reflect.TypeFor[Marshaler]()
+	unmarshalerType = reflect.TypeFor[Unmarshaler]()
+	rawType         = reflect.TypeFor[Raw]()
+	extType         = reflect.TypeFor[Ext]()
+	timeType        = reflect.TypeFor[time.Time]()
+	valueType       = reflect.TypeFor[Value]()
+)
+
+func buildPlan(t reflect.Type, building map[reflect.Type]*plan) *plan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*plan)
+	}
+	if p, ok := building[t]; ok {
+		return p
+	}
+	p := &plan{}
+	building[t] = p
+	p.encode = encoderFor(t, building)
+	p.decode = decoderFor(t, building)
+	return p
+}
+
+func encoderFor(t reflect.Type, building map[reflect.Type]*plan) func(*Encoder, reflect.Value) error {
+	if t.Implements(marshalerType) {
+		return func(e *Encoder, v reflect.Value) error {
+			if isNilable(v.Kind()) && v.IsNil() {
+				e.PutNil()
+				return nil
+			}
+			return v.Interface().(Marshaler).EncodeMsgpack(e)
+		}
+	}
+	if reflect.PointerTo(t).Implements(marshalerType) {
+		// The method needs a pointer so v may have to be copied
+		return func(e *Encoder, v reflect.Value) error {
+			if !v.CanAddr() {
+				c := reflect.New(t).Elem()
+				c.Set(v)
+				v = c
+			}
+			return v.Addr().Interface().(Marshaler).EncodeMsgpack(e)
+		}
+	}
+	switch t {
+	case rawType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.PutRaw(v.Bytes())
+		}
+	case extType:
+		return func(e *Encoder, v reflect.Value) error {
+			x := v.Interface().(Ext)
+			return e.PutExt(x.Type, x.Data)
+		}
+	case timeType:
+		return func(e *Encoder, v reflect.Value) error {
+			e.PutTime(v.Interface().(time.Time))
+			return nil
+		}
+	case valueType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.PutValue(v.Interface().(Value))
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(e *Encoder, v reflect.Value) error {
+			e.PutBool(v.Bool())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(e *Encoder, v reflect.Value) error {
+			e.PutInt(v.Int())
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(e *Encoder, v reflect.Value) error {
+			e.PutUint(v.Uint())
+			return nil
+		}
+	case reflect.Float32:
+		return func(e *Encoder, v reflect.Value) error {
+			e.PutFloat32(float32(v.Float()))
+			return nil
+		}
+	case reflect.Float64:
+		return func(e *Encoder, v reflect.Value) error {
+			e.PutFloat(v.Float())
+			return nil
+		}
+	case reflect.String:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.PutString(v.String())
+		}
+	case reflect.Interface:
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.PutNil()
+				return nil
+			}
+			v = v.Elem()
+			return planFor(v.Type()).encode(e, v)
+		}
+	case reflect.Pointer:
+		elem := buildPlan(t.Elem(), building)
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.PutNil()
+				return nil
+			}
+			return elem.encode(e, v.Elem())
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(e *Encoder, v reflect.Value) error {
+				if v.IsNil() {
+					e.PutNil()
+					return nil
+				}
+				return e.PutBinary(v.Bytes())
+			}
+		}
+		elem := buildPlan(t.Elem(), building)
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.PutNil()
+				return nil
+			}
+			return encodeElems(e, v, elem)
+		}
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(e *Encoder, v reflect.Value) error {
+				b := make([]byte, v.Len())
+				reflect.Copy(reflect.ValueOf(b), v)
+				return e.PutBinary(b)
+			}
+		}
+		elem := buildPlan(t.Elem(), building)
+		return func(e *Encoder, v reflect.Value) error {
+			return encodeElems(e, v, elem)
+		}
+	case reflect.Map:
+		key := buildPlan(t.Key(), building)
+		elem := buildPlan(t.Elem(), building)
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.PutNil()
+				return nil
+			}
+			keys := v.MapKeys()
+			return e.putMap(len(keys),
+				func(e *Encoder, i int) error {
+					return key.encode(e, keys[i])
+				},
+				func(e *Encoder, i int) error {
+					return elem.encode(e, v.MapIndex(keys[i]))
+				},
+			)
+		}
+	case reflect.Struct:
+		fields := structFields(t, building)
+		return func(e *Encoder, v reflect.Value) error {
+			var include []int
+			var values []reflect.Value
+			for i, f := range fields {
+				fv, ok := fieldByIndex(v, f.index)
+				if !ok || f.omitEmpty && fv.IsZero() {
+					continue
+				}
+				include = append(include, i)
+				values = append(values, fv)
+			}
+			return e.putMap(len(include),
+				func(e *Encoder, i int) error {
+					return e.PutString(fields[include[i]].name)
+				},
+				func(e *Encoder, i int) error {
+					return fields[include[i]].plan.encode(e, values[i])
+				},
+			)
+		}
+	default:
+		return func(e *Encoder, v reflect.Value) error {
+			return fmt.Errorf("can't encode type %s", t)
+		}
+	}
+}
+
+func decoderFor(t reflect.Type, building map[reflect.Type]*plan) func(*Decoder, reflect.Value) error {
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(unmarshalerType) {
+		return func(d *Decoder, v reflect.Value) error {
+			return v.Addr().Interface().(Unmarshaler).DecodeMsgpack(d)
+		}
+	}
+	switch t {
+	case rawType:
+		return func(d *Decoder, v reflect.Value) error {
+			raw, err := d.GetRaw()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(raw)
+			return nil
+		}
+	case extType:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			typ, data, err := d.GetExt()
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(Ext{Type: typ, Data: slices.Clone(data)}))
+			return nil
+		})
+	case timeType:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			tm, err := d.GetTime()
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(tm))
+			return nil
+		})
+	case valueType:
+		return func(d *Decoder, v reflect.Value) error {
+			value, err := d.GetValue()
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(value))
+			return nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			b, err := d.GetBool()
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			start := d.offset
+			i, err := d.GetInt()
+			if err != nil {
+				return err
+			}
+			if v.OverflowInt(i) {
+				return d.fail(&OverflowError{i, t.String()}, start)
+			}
+			v.SetInt(i)
+			return nil
+		})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			start := d.offset
+			u, err := d.GetUint()
+			if err != nil {
+				return err
+			}
+			if v.OverflowUint(u) {
+				return d.fail(&OverflowError{u, t.String()}, start)
+			}
+			v.SetUint(u)
+			return nil
+		})
+	case reflect.Float32:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			f, err := GetFloatAs[float32](d)
+			if err != nil {
+				return err
+			}
+			v.SetFloat(float64(f))
+			return nil
+		})
+	case reflect.Float64:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			f, err := d.GetFloat()
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+			return nil
+		})
+	case reflect.String:
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			s, err := d.GetString()
+			if err != nil {
+				return err
+			}
+			v.SetString(s)
+			return nil
+		})
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			break
+		}
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			value, err := d.GetValue()
+			if err != nil {
+				return err
+			}
+			x, err := value.Any()
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&x).Elem())
+			return nil
+		})
+	case reflect.Pointer:
+		elem := buildPlan(t.Elem(), building)
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			if v.IsNil() {
+				v.Set(reflect.New(t.Elem()))
+			}
+			return elem.decode(d, v.Elem())
+		})
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ifNotNil(func(d *Decoder, v reflect.Value) error {
+				b, err := d.GetBinaryCopy()
+				if err != nil {
+					return err
+				}
+				v.SetBytes(b)
+				return nil
+			})
+		}
+		elem := buildPlan(t.Elem(), building)
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			n, err := d.GetArrayLength()
+			if err != nil {
+				return err
+			}
+			s := reflect.MakeSlice(t, 0, d.ArrayCapacity(n))
+			for i := range int(n) {
+				s = reflect.Append(s, reflect.Zero(t.Elem()))
+				d.PushIndex(i)
+				err := elem.decode(d, s.Index(i))
+				d.Pop()
+				if err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		})
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ifNotNil(func(d *Decoder, v reflect.Value) error {
+				start := d.offset
+				b, err := d.GetBinaryView()
+				if err != nil {
+					return err
+				}
+				if len(b) != t.Len() {
+					return d.fail(fmt.Errorf("expected %d bytes, got %d", t.Len(), len(b)), start)
+				}
+				reflect.Copy(v, reflect.ValueOf(b))
+				return nil
+			})
+		}
+		elem := buildPlan(t.Elem(), building)
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			start := d.offset
+			n, err := d.GetArrayLength()
+			if err != nil {
+				return err
+			}
+			if int(n) != t.Len() {
+				return d.fail(fmt.Errorf("expected array of %d items, got %d", t.Len(), n), start)
+			}
+			for i := range int(n) {
+				d.PushIndex(i)
+				err := elem.decode(d, v.Index(i))
+				d.Pop()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Map:
+		key := buildPlan(t.Key(), building)
+		elem := buildPlan(t.Elem(), building)
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			n, err := d.GetMapLength()
+			if err != nil {
+				return err
+			}
+			m := reflect.MakeMapWithSize(t, d.MapCapacity(n))
+			var prev []byte
+			for range n {
+				k := reflect.New(t.Key()).Elem()
+				_, curr, err := decodeKey(d, prev, func() (struct{}, error) {
+					return struct{}{}, key.decode(d, k)
+				})
+				if err != nil {
+					return err
+				}
+				prev = curr
+				x := reflect.New(t.Elem()).Elem()
+				d.PushKeyValue(reflectKeyValue(k))
+				err = elem.decode(d, x)
+				d.Pop()
+				if err != nil {
+					return err
+				}
+				m.SetMapIndex(k, x)
+			}
+			v.Set(m)
+			return nil
+		})
+	case reflect.Struct:
+		fields := structFields(t, building)
+		index := make(map[string]int, len(fields))
+		for i, f := range fields {
+			index[f.name] = i
+		}
+		return ifNotNil(func(d *Decoder, v reflect.Value) error {
+			n, err := d.GetMapLength()
+			if err != nil {
+				return err
+			}
+			var prev []byte
+			for range n {
+				name, curr, named, err := decodeFieldName(d, prev)
+				if err != nil {
+					return err
+				}
+				prev = curr
+				if !named {
+					continue
+				}
+				i, ok := index[name]
+				if !ok {
+					if err := d.Skip(); err != nil {
+						return err
+					}
+					continue
+				}
+				f := fields[i]
+				fv, err := fieldByIndexAlloc(v, f.index)
+				if err != nil {
+					return d.fail(err, d.offset)
+				}
+				d.PushKey(f.name)
+				err = f.plan.decode(d, fv)
+				d.Pop()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return func(d *Decoder, v reflect.Value) error {
+		return fmt.Errorf("can't decode into type %s", t)
+	}
+}
+
+// Decoding nil into any type sets it to its zero value
+func ifNotNil(f func(*Decoder, reflect.Value) error) func(*Decoder, reflect.Value) error {
+	return func(d *Decoder, v reflect.Value) error {
+		isNil, err := d.IfNil()
+		if err != nil {
+			return err
+		}
+		if isNil {
+			v.SetZero()
+			return nil
+		}
+		return f(d, v)
+	}
+}
+
+// Converts a map key to a Value for a decoder path, as keyValue does.
+// Named types may format themselves differently so are formatted
+// straight away.
+func reflectKeyValue(k reflect.Value) Value {
+	if k.Type().PkgPath() == "" {
+		switch k.Kind() {
+		case reflect.String:
+			return StringValue(k.String())
+		case reflect.Bool:
+			return BoolValue(k.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return IntValue(k.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return UintValue(k.Uint())
+		case reflect.Float64:
+			return FloatValue(k.Float())
+		}
+	}
+	return StringValue(fmt.Sprint(k.Interface()))
+}
+
+func encodeElems(e *Encoder, v reflect.Value, elem *plan) error {
+	n := v.Len()
+	if n > mask32 {
+		return fmt.Errorf("array (%d items) is %w", n, ErrTooLong)
+	}
+	e.PutArrayLength(uint32(n))
+	for i := range n {
+		if err := elem.encode(e, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isNilable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice:
+		return true
+	default:
+		return false
+	}
+}
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	plan      *plan
+}
+
+// Lists the fields of struct type t which get encoded, including those
+// of embedded structs
+func structFields(t reflect.Type, building map[reflect.Type]*plan) []structField {
+	var fields []structField
+	depths := map[string]int{}
+	var walk func(t reflect.Type, index []int, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("msgpack")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft, append(index[:len(index):len(index)], i), visited)
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			depth := len(index)
+			if d, ok := depths[name]; ok && d <= depth {
+				// Hidden by a field of the same name in an outer
+				// struct, or an earlier one at the same depth
+				continue
+			}
+			depths[name] = depth
+			fields = slices.DeleteFunc(fields, func(f structField) bool {
+				return f.name == name
+			})
+			fields = append(fields, structField{
+				name:      name,
+				index:     append(index[:len(index):len(index)], i),
+				omitEmpty: opts == "omitempty",
+				plan:      buildPlan(sf.Type, building),
+			})
+		}
+	}
+	walk(t, nil, map[reflect.Type]bool{})
+	return fields
+}
+
+// Returns the field of v at index, or false if it is inside an embedded
+// struct reached through a nil pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// Returns the field of v at index, allocating any embedded structs on
+// the way to it which are reached through nil pointers
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("can't set embedded pointer to unexported type %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
@@ -0,0 +1,175 @@
+package msgpack
+
+import (
+	"fmt"
+	"math"
+)
+
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+type Float interface {
+	~float32 | ~float64
+}
+
+func GetInteger[T Integer](d *Decoder) (T, error) {
+	start := d.offset
+	if signed[T]() {
+		n, err := d.getNumber(KindInt)
+		if err != nil {
+			return 0, err
+		}
+		v, err := n.toInt()
+		if err == nil && int64(T(v)) != v {
+			err = &OverflowError{v, ""}
+		}
+		if err != nil {
+			return 0, d.fail(retype[T](err), start)
+		}
+		return T(v), nil
+	}
+	n, err := d.getNumber(KindUint)
+	if err != nil {
+		return 0, err
+	}
+	v, err := n.toUint()
+	if err == nil && uint64(T(v)) != v {
+		err = &OverflowError{v, ""}
+	}
+	if err != nil {
+		return 0, d.fail(retype[T](err), start)
+	}
+	return T(v), nil
+}
+
+func GetFloatAs[T Float](d *Decoder) (T, error) {
+	start := d.offset
+	n, err := d.getNumber(KindFloat)
+	if err != nil {
+		return 0, err
+	}
+	v, err := n.toFloat()
+	if err == nil {
+		f := float64(T(v))
+		if math.IsInf(f, 0) && !math.IsInf(v, 0) {
+			err = &OverflowError{v, ""}
+		} else if f != v && !math.IsNaN(v) {
+			err = &PrecisionError{v, ""}
+		}
+	}
+	if err != nil {
+		return 0, d.fail(retype[T](err), start)
+	}
+	return T(v), nil
+}
+
+func PutInteger[T Integer](e *Encoder, v T) {
+	if signed[T]() {
+		e.PutInt(int64(v))
+	} else {
+		e.PutUint(uint64(v))
+	}
+}
+
+// Conversion errors are reported against the caller's type rather
+// than the 64 bit type used while decoding
+func retype[T any](err error) error {
+	typ := fmt.Sprintf("%T", *new(T))
+	switch err := err.(type) {
+	case *OverflowError:
+		err.Type = typ
+	case *PrecisionError:
+		err.Type = typ
+	}
+	return err
+}
+
+func signed[T Integer]() bool {
+	return ^T(0) < 0
+}
+
+// A number holds a decoded int, uint or float before it is converted
+// to the type the caller asked for
+type number struct {
+	kind Kind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+// 2^63 and 2^64 as floats, the first values beyond the int64 and
+// uint64 ranges
+const (
+	twoTo63 = float64(1 << 63)
+	twoTo64 = twoTo63 * 2
+)
+
+func (n number) toFloat() (float64, error) {
+	const typ = "float64"
+	switch n.kind {
+	case KindInt:
+		f := float64(n.i)
+		if f >= twoTo63 || int64(f) != n.i {
+			return 0, &PrecisionError{n.i, typ}
+		}
+		return f, nil
+	case KindUint:
+		f := float64(n.u)
+		if f >= twoTo64 || uint64(f) != n.u {
+			return 0, &PrecisionError{n.u, typ}
+		}
+		return f, nil
+	default:
+		return n.f, nil
+	}
+}
+
+func (n number) toInt() (int64, error) {
+	const typ = "int64"
+	switch n.kind {
+	case KindInt:
+		return n.i, nil
+	case KindUint:
+		if n.u > math.MaxInt64 {
+			return 0, &OverflowError{n.u, typ}
+		}
+		return int64(n.u), nil
+	default:
+		if math.IsNaN(n.f) || math.IsInf(n.f, 0) {
+			return 0, &OverflowError{n.f, typ}
+		}
+		if n.f != math.Trunc(n.f) {
+			return 0, &PrecisionError{n.f, typ}
+		}
+		if n.f < -twoTo63 || n.f >= twoTo63 {
+			return 0, &OverflowError{n.f, typ}
+		}
+		return int64(n.f), nil
+	}
+}
+
+func (n number) toUint() (uint64, error) {
+	const typ = "uint64"
+	switch n.kind {
+	case KindInt:
+		if n.i < 0 {
+			return 0, &OverflowError{n.i, typ}
+		}
+		return uint64(n.i), nil
+	case KindUint:
+		return n.u, nil
+	default:
+		if math.IsNaN(n.f) || math.IsInf(n.f, 0) {
+			return 0, &OverflowError{n.f, typ}
+		}
+		if n.f != math.Trunc(n.f) {
+			return 0, &PrecisionError{n.f, typ}
+		}
+		if n.f < 0 || n.f >= twoTo64 {
+			return 0, &OverflowError{n.f, typ}
+		}
+		return uint64(n.f), nil
+	}
+}
@@ -0,0 +1,110 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrNeedMore = errors.New("need more input")
+
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// A Parser splits input that arrives in arbitrary pieces into complete
+// values without ever blocking. Each piece is passed to Feed and then
+// Next is called until it returns ErrNeedMore.
+//
+// The scan of a partly received value is kept between calls to Next so
+// its bytes are only examined once, however many pieces it arrives in.
+type Parser struct {
+	buf     []byte // starts with the value being scanned
+	pos     int    // how far into buf the scan has got
+	pending int    // values still to be scanned, including nested ones
+	offset  int    // of buf[0] from the start of the input
+	err     error
+}
+
+// Adds b to the input. The bytes are copied so b can be reused.
+func (p *Parser) Feed(b []byte) {
+	p.buf = append(p.buf, b...)
+}
+
+// Buffered returns the number of bytes fed but not yet returned by Next
+func (p *Parser) Buffered() int {
+	return len(p.buf)
+}
+
+// Offset returns the offset of the next value from the start of the input
+func (p *Parser) Offset() int {
+	return p.offset
+}
+
+// Returns the next complete value, or ErrNeedMore if it hasn't been
+// fed in full yet. The value can be decoded with NewDecoder and stays
+// valid after further calls to Feed and Next.
+//
+// Malformed input can't be recovered from, so once Next has returned
+// any other error it returns the same error every time.
+func (p *Parser) Next() (Raw, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.pending == 0 {
+		if len(p.buf) == 0 {
+			return nil, ErrNeedMore
+		}
+		p.pending = 1
+	}
+	for p.pending > 0 {
+		items, err := p.scan()
+		if err != nil {
+			return nil, err
+		}
+		p.pending += items - 1
+	}
+	raw := Raw(p.buf[:p.pos:p.pos])
+	p.buf = p.buf[p.pos:]
+	p.offset += p.pos
+	p.pos = 0
+	return raw, nil
+}
+
+// Scans past a single value at pos, as Decoder.skipValue does, and
+// returns the number of nested values that follow it. If the value
+// isn't all there pos is left where it was.
+func (p *Parser) scan() (int, error) {
+	if p.pos >= len(p.buf) {
+		return 0, ErrNeedMore
+	}
+	b := p.buf[p.pos]
+	f := formats[b]
+	if !f.valid {
+		offset := p.offset + p.pos
+		p.err = &DecodeError{
+			Path:   "$",
+			Offset: offset,
+			Err:    &FormatError{Got: b, Offset: offset},
+		}
+		return 0, p.err
+	}
+	head := 1 + f.length
+	if p.pos+head > len(p.buf) {
+		return 0, ErrNeedMore
+	}
+	n := f.size
+	switch field := p.buf[p.pos+1 : p.pos+head]; f.length {
+	case 1:
+		n = uint32(field[0])
+	case 2:
+		n = uint32(binary.BigEndian.Uint16(field))
+	case 4:
+		n = binary.BigEndian.Uint32(field)
+	}
+	end := p.pos + head + f.skip(n)
+	if end > len(p.buf) {
+		return 0, ErrNeedMore
+	}
+	p.pos = end
+	return f.items(n), nil
+}
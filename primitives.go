@@ -0,0 +1,85 @@
+package msgpack
+
+import (
+	"time"
+)
+
+// Codecs for the types the Encoder and Decoder support directly. The
+// integer and float codecs accept any number that fits the type
+// exactly (see GetInteger and GetFloatAs).
+var (
+	Bool = Codec[bool]{
+		Decode: (*Decoder).GetBool,
+		Encode: func(e *Encoder, v bool) error {
+			e.PutBool(v)
+			return nil
+		},
+	}
+
+	Int   = integer[int]()
+	Int8  = integer[int8]()
+	Int16 = integer[int16]()
+	Int32 = integer[int32]()
+	Int64 = integer[int64]()
+
+	Uint   = integer[uint]()
+	Uint8  = integer[uint8]()
+	Uint16 = integer[uint16]()
+	Uint32 = integer[uint32]()
+	Uint64 = integer[uint64]()
+
+	Float32 = Codec[float32]{
+		Decode: GetFloatAs[float32],
+		Encode: func(e *Encoder, v float32) error {
+			e.PutFloat32(v)
+			return nil
+		},
+	}
+
+	Float64 = Codec[float64]{
+		Decode: (*Decoder).GetFloat,
+		Encode: func(e *Encoder, v float64) error {
+			e.PutFloat(v)
+			return nil
+		},
+	}
+
+	String = Codec[string]{
+		Decode: (*Decoder).GetString,
+		Encode: (*Encoder).PutString,
+	}
+
+	// Decodes into a copy, so the result never shares the input
+	Bytes = Codec[[]byte]{
+		Decode: (*Decoder).GetBinaryCopy,
+		Encode: (*Encoder).PutBinary,
+	}
+
+	Time = Codec[time.Time]{
+		Decode: (*Decoder).GetTime,
+		Encode: func(e *Encoder, v time.Time) error {
+			e.PutTime(v)
+			return nil
+		},
+	}
+
+	RawCodec = Codec[Raw]{
+		Decode: (*Decoder).GetRaw,
+		Encode: (*Encoder).PutRaw,
+	}
+
+	ValueCodec = Codec[Value]{
+		Decode: (*Decoder).GetValue,
+		Encode: (*Encoder).PutValue,
+	}
+)
+
+func integer[T Integer]() Codec[T] {
+	return Codec[T]{
+		Decode: GetInteger[T],
+		Encode: func(e *Encoder, v T) error {
+			PutInteger(e, v)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,4 @@
+package msgpack
+
+// A Raw holds the encoding of exactly one complete value
+type Raw []byte
@@ -0,0 +1,230 @@
+package msgpack
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Builds a codec for the struct (or any other) type T out of a codec
+// for each of its fields, e.g.
+//
+//	var userCodec = Struct[User]().
+//		Field(Field("id", func(u *User) *int64 { return &u.ID }, Int64)).
+//		Field(Field("email", func(u *User) *string { return &u.Email }, String).Optional()).
+//		Build()
+//
+// By default T is encoded as a map from field name to value. Decoding
+// accepts the keys in any order, skips keys it doesn't know and fails
+// if a field that isn't optional is missing.
+func Struct[T any]() *StructBuilder[T] {
+	return &StructBuilder[T]{}
+}
+
+type StructBuilder[T any] struct {
+	fields  []StructField[T]
+	asArray bool
+}
+
+func (b *StructBuilder[T]) Field(f StructField[T]) *StructBuilder[T] {
+	b.fields = append(b.fields, f)
+	return b
+}
+
+// Encode T as an array of its field values in the order the fields
+// were added, rather than as a map. Decoding ignores any values beyond
+// the last field and fails if the array stops short of a field that
+// isn't optional.
+func (b *StructBuilder[T]) AsArray() *StructBuilder[T] {
+	b.asArray = true
+	return b
+}
+
+// Returns the codec. It panics if two fields have the same name.
+func (b *StructBuilder[T]) Build() Codec[T] {
+	s := &structCodec[T]{
+		fields: slices.Clone(b.fields),
+		index:  make(map[string]int, len(b.fields)),
+	}
+	for i, f := range s.fields {
+		if _, ok := s.index[f.name]; ok {
+			panic(fmt.Sprintf("msgpack: duplicate field %q", f.name))
+		}
+		s.index[f.name] = i
+	}
+	if b.asArray {
+		return Codec[T]{Decode: s.decodeArray, Encode: s.encodeArray}
+	}
+	return Codec[T]{Decode: s.decodeMap, Encode: s.encodeMap}
+}
+
+// A StructField describes one field of T. See Field.
+type StructField[T any] struct {
+	name     string
+	optional bool
+	decode   func(*Decoder, *T) error
+	encode   func(*Encoder, *T) error
+}
+
+// Describes a field called name, which get locates within a T and c
+// encodes and decodes
+func Field[T, F any](name string, get func(*T) *F, c Codec[F]) StructField[T] {
+	return StructField[T]{
+		name: name,
+		decode: func(d *Decoder, v *T) error {
+			f, err := c.Decode(d)
+			if err != nil {
+				return err
+			}
+			*get(v) = f
+			return nil
+		},
+		encode: func(e *Encoder, v *T) error {
+			return c.Encode(e, *get(v))
+		},
+	}
+}
+
+// Allows the field to be missing when decoding, in which case it is
+// left as it is
+func (f StructField[T]) Optional() StructField[T] {
+	f.optional = true
+	return f
+}
+
+type structCodec[T any] struct {
+	fields []StructField[T]
+	index  map[string]int
+}
+
+// Reads the key of a struct field as decodeKey does. Only a string can
+// name a field so any other key is skipped along with its value, and
+// named is false.
+func decodeFieldName(d *Decoder, prev []byte) (name string, curr []byte, named bool, err error) {
+	k, err := d.PeekKind()
+	if err != nil {
+		return "", nil, false, err
+	}
+	if k != KindString {
+		_, curr, err = decodeKey(d, prev, func() (struct{}, error) {
+			return struct{}{}, d.Skip()
+		})
+		if err == nil {
+			err = d.Skip()
+		}
+		return "", curr, false, err
+	}
+	name, curr, err = decodeKey(d, prev, d.GetStringView)
+	return name, curr, true, err
+}
+
+func (s *structCodec[T]) decodeMap(d *Decoder) (T, error) {
+	var v T
+	start := d.offset
+	n, err := d.GetMapLength()
+	if err != nil {
+		return v, err
+	}
+	seen := make([]bool, len(s.fields))
+	var prev []byte
+	for range n {
+		keyStart := d.offset
+		key, curr, named, err := decodeFieldName(d, prev)
+		if err != nil {
+			return v, err
+		}
+		prev = curr
+		if !named {
+			continue
+		}
+		i, ok := s.index[key]
+		if !ok {
+			if err := d.Skip(); err != nil {
+				return v, err
+			}
+			continue
+		}
+		if seen[i] {
+			return v, d.fail(fmt.Errorf("duplicate field %q", key), keyStart)
+		}
+		seen[i] = true
+		if err := s.decodeField(d, i, &v); err != nil {
+			return v, err
+		}
+	}
+	if err := s.missing(seen); err != nil {
+		return v, d.fail(err, start)
+	}
+	return v, nil
+}
+
+func (s *structCodec[T]) encodeMap(e *Encoder, v T) error {
+	return e.putMap(len(s.fields),
+		func(e *Encoder, i int) error {
+			return e.PutString(s.fields[i].name)
+		},
+		func(e *Encoder, i int) error {
+			return s.fields[i].encode(e, &v)
+		},
+	)
+}
+
+func (s *structCodec[T]) decodeArray(d *Decoder) (T, error) {
+	var v T
+	start := d.offset
+	n, err := d.GetArrayLength()
+	if err != nil {
+		return v, err
+	}
+	seen := make([]bool, len(s.fields))
+	for i := range int(n) {
+		if i >= len(s.fields) {
+			if err := d.Skip(); err != nil {
+				return v, err
+			}
+			continue
+		}
+		seen[i] = true
+		if err := s.decodeField(d, i, &v); err != nil {
+			return v, err
+		}
+	}
+	if err := s.missing(seen); err != nil {
+		return v, d.fail(err, start)
+	}
+	return v, nil
+}
+
+func (s *structCodec[T]) encodeArray(e *Encoder, v T) error {
+	e.PutArrayLength(uint32(len(s.fields)))
+	for _, f := range s.fields {
+		if err := f.encode(e, &v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *structCodec[T]) decodeField(d *Decoder, i int, v *T) error {
+	d.PushKey(s.fields[i].name)
+	defer d.Pop()
+	return s.fields[i].decode(d, v)
+}
+
+// Reports the required fields not seen
+func (s *structCodec[T]) missing(seen []bool) error {
+	var names []string
+	for i, f := range s.fields {
+		if !seen[i] && !f.optional {
+			names = append(names, fmt.Sprintf("%q", f.name))
+		}
+	}
+	switch len(names) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("missing field %s", names[0])
+	default:
+		return fmt.Errorf("missing fields %s", strings.Join(names, ", "))
+	}
+}
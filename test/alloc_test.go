@@ -0,0 +1,105 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+var putPayload = []byte{1, 2, 3, 4, 5}
+
+var puts = []struct {
+	name string
+	put  func(*msgpack.Encoder)
+}{
+	{"nil", func(e *msgpack.Encoder) { e.PutNil() }},
+	{"bool", func(e *msgpack.Encoder) { e.PutBool(true) }},
+	{"int", func(e *msgpack.Encoder) { e.PutInt(-100000) }},
+	{"int64", func(e *msgpack.Encoder) { e.PutInt(-1 << 40) }},
+	{"uint", func(e *msgpack.Encoder) { e.PutUint(1 << 40) }},
+	{"float32", func(e *msgpack.Encoder) { e.PutFloat32(1.5) }},
+	{"float64", func(e *msgpack.Encoder) { e.PutFloat64(1.1) }},
+	{"string", func(e *msgpack.Encoder) { e.PutString("hello, world") }},
+	{"binary", func(e *msgpack.Encoder) { e.PutBinary(putPayload) }},
+	{"array", func(e *msgpack.Encoder) { e.PutArrayLength(1000) }},
+	{"map", func(e *msgpack.Encoder) { e.PutMapLength(1000) }},
+	{"ext", func(e *msgpack.Encoder) { e.PutExt(1, putPayload[:3]) }},
+	{"ext uint", func(e *msgpack.Encoder) { e.PutExtUint(1, 1<<20) }},
+	{"time", func(e *msgpack.Encoder) { e.PutTime(time.Unix(1<<35, 5)) }},
+}
+
+func TestEncoderAllocations(t *testing.T) {
+	for _, p := range puts {
+		t.Run(p.name, func(t *testing.T) {
+			mpe := msgpack.NewEncoder()
+			mpe.Grow(64)
+			a := testing.AllocsPerRun(100, func() {
+				mpe.Reset()
+				p.put(mpe)
+			})
+			if a != 0 {
+				report(t, a, 0)
+			}
+		})
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	mpe := msgpack.NewEncoder()
+	mpe.PutString("abc")
+	b := mpe.Bytes()
+	mpe.Reset()
+	if len(mpe.Bytes()) != 0 {
+		report(t, len(mpe.Bytes()), 0)
+	}
+	mpe.PutInt(1)
+	if &mpe.Bytes()[0] != &b[0] {
+		report(t, "new buffer", "same buffer")
+	}
+}
+
+func TestEncoderPool(t *testing.T) {
+	mpe := msgpack.AcquireEncoder(msgpack.WithCanonical())
+	mpe.PutFloat(1)
+	if mpe.AsString(-1) != "ca 3f 80 00 00" {
+		report(t, mpe.AsString(-1), "ca 3f 80 00 00")
+	}
+	msgpack.ReleaseEncoder(mpe)
+
+	mpe = msgpack.AcquireEncoder()
+	if len(mpe.Bytes()) != 0 {
+		report(t, len(mpe.Bytes()), 0)
+	}
+	mpe.PutFloat(1)
+	if mpe.AsString(-1) != "ca 3f 80 00 00" {
+		report(t, mpe.AsString(-1), "ca 3f 80 00 00")
+	}
+	msgpack.ReleaseEncoder(mpe)
+}
+
+func BenchmarkPut(b *testing.B) {
+	for _, p := range puts {
+		b.Run(p.name, func(b *testing.B) {
+			mpe := msgpack.NewEncoder()
+			b.ReportAllocs()
+			for b.Loop() {
+				mpe.Reset()
+				p.put(mpe)
+			}
+		})
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		mpe := msgpack.AcquireEncoder()
+		mpe.PutMapLength(2)
+		mpe.PutString("id")
+		mpe.PutInt(12345)
+		mpe.PutString("name")
+		mpe.PutString("abcdefgh")
+		msgpack.ReleaseEncoder(mpe)
+	}
+}
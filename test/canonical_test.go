@@ -0,0 +1,134 @@
+package test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestCanonicalEncoder(t *testing.T) {
+	run := func(t *testing.T, put func(*msgpack.Encoder), e string) {
+		mpe := msgpack.NewEncoder(msgpack.WithCanonical())
+		put(mpe)
+		mps := mpe.AsString(-1)
+		if mps != e {
+			report(t, mps, e)
+		}
+		mpd := msgpack.NewDecoder(mpe.Bytes(), msgpack.WithStrictCanonical())
+		_, err := mpd.GetValue()
+		if err != nil {
+			report(t, err, nil)
+		}
+	}
+
+	t.Run("int", func(t *testing.T) {
+		t.Run("positive", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(200) }, "cc c8")
+		})
+		t.Run("negative", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(-200) }, "d1 ff 38")
+		})
+	})
+
+	t.Run("float", func(t *testing.T) {
+		t.Run("exact single", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat64(1.5) }, "ca 3f c0 00 00")
+		})
+		t.Run("double", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat32(0.1) }, "ca 3d cc cc cd")
+			run(t, func(e *msgpack.Encoder) { e.PutFloat(0.1) }, "cb 3f b9 99 99 99 99 99 9a")
+		})
+		t.Run("nan", func(t *testing.T) {
+			nan := math.Float64frombits(0xfff8000000000001)
+			run(t, func(e *msgpack.Encoder) { e.PutFloat64(nan) }, "ca 7f c0 00 00")
+		})
+	})
+
+	t.Run("map keys", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) {
+			e.PutValue(msgpack.MapValue(
+				msgpack.Entry{Key: msgpack.StringValue("b"), Value: msgpack.IntValue(1)},
+				msgpack.Entry{Key: msgpack.IntValue(1), Value: msgpack.IntValue(2)},
+				msgpack.Entry{Key: msgpack.StringValue("a"), Value: msgpack.IntValue(3)},
+			))
+		}, "83 01 02 a1 61 03 a1 62 01")
+	})
+
+	t.Run("duplicate keys", func(t *testing.T) {
+		mpe := msgpack.NewEncoder(msgpack.WithCanonical())
+		err := mpe.PutValue(msgpack.MapValue(
+			msgpack.Entry{Key: msgpack.IntValue(1), Value: msgpack.NilValue()},
+			msgpack.Entry{Key: msgpack.UintValue(1), Value: msgpack.NilValue()},
+		))
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+}
+
+func TestStrictCanonical(t *testing.T) {
+	run := func(t *testing.T, b []byte, ok bool) {
+		// Skipping a value has to check it just as decoding it does
+		for _, get := range []func(*msgpack.Decoder) error{
+			func(mpd *msgpack.Decoder) error {
+				_, err := mpd.GetValue()
+				return err
+			},
+			(*msgpack.Decoder).Skip,
+			func(mpd *msgpack.Decoder) error {
+				_, err := mpd.GetRaw()
+				return err
+			},
+		} {
+			err := get(msgpack.NewDecoder(b, msgpack.WithStrictCanonical()))
+			if ok && err != nil {
+				report(t, err, nil)
+			}
+			if !ok && !errors.Is(err, msgpack.ErrNotCanonical) {
+				report(t, err, msgpack.ErrNotCanonical)
+			}
+		}
+	}
+
+	t.Run("int", func(t *testing.T) {
+		run(t, []byte{0x05}, true)
+		run(t, []byte{0xd0, 0x05}, false)
+		run(t, []byte{0xcc, 0x05}, false)
+		run(t, []byte{0xd1, 0x00, 0xc8}, false)
+		run(t, []byte{0xd1, 0xff, 0x80}, false)
+	})
+
+	t.Run("float", func(t *testing.T) {
+		run(t, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, false)
+		run(t, []byte{0xcb, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0}, false)
+		run(t, []byte{0xca, 0x7f, 0xc0, 0, 1}, false)
+		run(t, []byte{0xca, 0x7f, 0xc0, 0, 0}, true)
+	})
+
+	t.Run("lengths", func(t *testing.T) {
+		run(t, []byte{0xd9, 0x01, 0x61}, false)
+		run(t, []byte{0xc5, 0x00, 0x01, 0x61}, false)
+		run(t, []byte{0xdc, 0x00, 0x00}, false)
+		run(t, []byte{0xde, 0x00, 0x00}, false)
+		run(t, []byte{0xc7, 0x01, 0x01, 0x61}, false)
+		run(t, []byte{0xd4, 0x01, 0x61}, true)
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		run(t, []byte{0xd7, 0xff, 0, 0, 0, 0, 0, 0, 0, 1}, false)
+		run(t, []byte{0xd6, 0xff, 0, 0, 0, 1}, true)
+	})
+
+	t.Run("map key order", func(t *testing.T) {
+		run(t, []byte{0x82, 0xa1, 0x62, 0xc0, 0xa1, 0x61, 0xc0}, false)
+		run(t, []byte{0x82, 0xa1, 0x61, 0xc0, 0xa1, 0x61, 0xc0}, false)
+		run(t, []byte{0x82, 0xa1, 0x61, 0xc0, 0xa1, 0x62, 0xc0}, true)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		run(t, []byte{0x91, 0x81, 0xa1, 0x61, 0xd0, 0x05}, false)
+		run(t, []byte{0x91, 0x81, 0xa1, 0x61, 0x05}, true)
+	})
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestChunked(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 3000)
+	sizes := []int{0, 20, 300, len(payload)}
+
+	t.Run("put", func(t *testing.T) {
+		for _, n := range sizes {
+			for _, stream := range []bool{false, true} {
+				var buf bytes.Buffer
+				mpe := msgpack.NewEncoder()
+				if stream {
+					mpe = msgpack.NewStreamEncoder(&buf)
+				}
+				r := iotest.HalfReader(bytes.NewReader(payload[:n]))
+				if err := mpe.PutBinaryFrom(r, int64(n)); err != nil {
+					report(t, err, nil)
+				}
+				r = iotest.HalfReader(bytes.NewReader(payload[:n]))
+				if err := mpe.PutStringFrom(r, int64(n)); err != nil {
+					report(t, err, nil)
+				}
+				mpe.Flush()
+				a := mpe.Bytes()
+				if stream {
+					a = buf.Bytes()
+				}
+				exp := msgpack.NewEncoder()
+				exp.PutBinary(payload[:n])
+				exp.PutString(string(payload[:n]))
+				if !bytes.Equal(a, exp.Bytes()) {
+					report(t, len(a), len(exp.Bytes()))
+				}
+			}
+		}
+	})
+
+	t.Run("put short", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutNil()
+		err := mpe.PutBinaryFrom(bytes.NewReader(payload[:10]), 20)
+		if err != io.ErrUnexpectedEOF {
+			report(t, err, io.ErrUnexpectedEOF)
+		}
+		if mpe.AsString(-1) != "c0" {
+			report(t, mpe.AsString(-1), "c0")
+		}
+
+		var buf bytes.Buffer
+		mpe = msgpack.NewStreamEncoder(&buf)
+		err = mpe.PutStringFrom(bytes.NewReader(payload[:10]), 20)
+		if err != io.ErrUnexpectedEOF {
+			report(t, err, io.ErrUnexpectedEOF)
+		}
+		if mpe.Err() != nil || mpe.Len() != 0 {
+			report(t, mpe.Err(), nil)
+		}
+
+		// Too much has been written to take it back
+		n := int64(len(payload))
+		err = mpe.PutStringFrom(bytes.NewReader(payload), n+1)
+		if err != io.ErrUnexpectedEOF {
+			report(t, err, io.ErrUnexpectedEOF)
+		}
+		if mpe.Err() != io.ErrUnexpectedEOF {
+			report(t, mpe.Err(), io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		for _, n := range sizes {
+			mpe.PutBinary(payload[:n])
+			mpe.PutString(string(payload[:n]))
+		}
+		mpe.PutNil()
+		data := mpe.Bytes()
+
+		run := func(t *testing.T, mpd *msgpack.Decoder, partial bool) {
+			for _, n := range sizes {
+				for _, get := range []func() (io.Reader, int64, error){mpd.GetBinaryReader, mpd.GetStringReader} {
+					r, m, err := get()
+					if err != nil {
+						report(t, err, nil)
+						return
+					}
+					if m != int64(n) {
+						report(t, m, n)
+					}
+					if partial {
+						// Read some and leave the rest to be skipped
+						b := make([]byte, n/2)
+						io.ReadFull(r, b)
+						continue
+					}
+					b, err := io.ReadAll(r)
+					if err != nil || !bytes.Equal(b, payload[:n]) {
+						report(t, len(b), n)
+					}
+				}
+			}
+			if isNil, err := mpd.IsNil(); err != nil || !isNil {
+				report(t, err, nil)
+			}
+		}
+
+		t.Run("slice", func(t *testing.T) {
+			run(t, msgpack.NewDecoder(data), false)
+		})
+
+		t.Run("stream", func(t *testing.T) {
+			run(t, msgpack.NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(data))), false)
+		})
+
+		t.Run("stream partial", func(t *testing.T) {
+			run(t, msgpack.NewStreamDecoder(bytes.NewReader(data)), true)
+		})
+
+		t.Run("raw", func(t *testing.T) {
+			mpd := msgpack.NewStreamDecoder(bytes.NewReader(data))
+			for range 2 * len(sizes) {
+				mpd.Skip()
+			}
+			raw, err := mpd.GetRaw()
+			if err != nil || !bytes.Equal(raw, []byte{0xc0}) {
+				report(t, raw, []byte{0xc0})
+			}
+		})
+	})
+
+	t.Run("get truncated", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutBinary(payload)
+		data := mpe.Bytes()
+		mpd := msgpack.NewStreamDecoder(bytes.NewReader(data[:len(data)-1]))
+		r, _, err := mpd.GetBinaryReader()
+		if err != nil {
+			report(t, err, nil)
+		}
+		_, err = io.ReadAll(r)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			report(t, err, io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("get wrong type", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0xa1, 0x61})
+		_, _, err := mpd.GetBinaryReader()
+		var te *msgpack.TypeError
+		if !errors.As(err, &te) || te.Expected != msgpack.KindBinary {
+			report(t, err, "*TypeError")
+		}
+	})
+}
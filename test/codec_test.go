@@ -0,0 +1,204 @@
+package test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func roundTrip[T any](t *testing.T, c msgpack.Codec[T], v T, e string, opts ...msgpack.EncoderOption) T {
+	t.Helper()
+	mpe := msgpack.NewEncoder(opts...)
+	if err := c.Encode(mpe, v); err != nil {
+		report(t, err, nil)
+	}
+	if mps := mpe.AsString(-1); mps != e {
+		report(t, mps, e)
+	}
+	mpd := msgpack.NewDecoder(mpe.Bytes())
+	a, err := c.Decode(mpd)
+	if err != nil {
+		report(t, err, nil)
+	}
+	if !mpd.IsEmpty() {
+		report(t, mpd.Length(), 0)
+	}
+	return a
+}
+
+func decodeError[T any](t *testing.T, c msgpack.Codec[T], b []byte) error {
+	t.Helper()
+	_, err := c.Decode(msgpack.NewDecoder(b))
+	if err == nil {
+		report(t, err, "error")
+	}
+	return err
+}
+
+func TestSliceOf(t *testing.T) {
+	c := msgpack.SliceOf(msgpack.Int64)
+
+	t.Run("values", func(t *testing.T) {
+		a := roundTrip(t, c, []int64{1, -1, 300}, "93 01 ff d1 01 2c")
+		if len(a) != 3 || a[2] != 300 {
+			report(t, a, []int64{1, -1, 300})
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		a := roundTrip(t, c, []int64{}, "90")
+		if a == nil || len(a) != 0 {
+			report(t, a, []int64{})
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		a := roundTrip(t, c, nil, "c0")
+		if a != nil {
+			report(t, a, nil)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		roundTrip(t, msgpack.SliceOf(c), [][]int64{{1}, nil}, "92 91 01 c0")
+	})
+
+	t.Run("bad item", func(t *testing.T) {
+		err := decodeError(t, c, []byte{0x92, 0x01, 0xa1, 0x61})
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) || de.Path != "$[1]" {
+			report(t, err, "at $[1]")
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		err := decodeError(t, c, []byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x01})
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			report(t, err, io.ErrUnexpectedEOF)
+		}
+	})
+}
+
+func TestArrayOf(t *testing.T) {
+	c := msgpack.ArrayOf(2, msgpack.String)
+
+	t.Run("values", func(t *testing.T) {
+		a := roundTrip(t, c, []string{"a", "b"}, "92 a1 61 a1 62")
+		if len(a) != 2 || a[1] != "b" {
+			report(t, a, []string{"a", "b"})
+		}
+	})
+
+	t.Run("wrong length", func(t *testing.T) {
+		if err := c.Encode(msgpack.NewEncoder(), []string{"a"}); err == nil {
+			report(t, err, "error")
+		}
+		err := decodeError(t, c, []byte{0x91, 0xa1, 0x61})
+		if !strings.Contains(err.Error(), "expected array of 2 items, got 1") {
+			report(t, err, "expected array of 2 items, got 1")
+		}
+	})
+}
+
+func TestMapOf(t *testing.T) {
+	c := msgpack.MapOf(msgpack.String, msgpack.Int64)
+
+	t.Run("values", func(t *testing.T) {
+		m := map[string]int64{"b": 2, "a": 1}
+		a := roundTrip(t, c, m, "82 a1 61 01 a1 62 02", msgpack.WithCanonical())
+		if len(a) != 2 || a["a"] != 1 || a["b"] != 2 {
+			report(t, a, m)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		a := roundTrip(t, c, nil, "c0")
+		if a != nil {
+			report(t, a, nil)
+		}
+	})
+
+	t.Run("bad value", func(t *testing.T) {
+		err := decodeError(t, c, []byte{0x81, 0xa1, 0x61, 0xc3})
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) || de.Path != "$.a" {
+			report(t, err, "at $.a")
+		}
+	})
+
+	t.Run("duplicate key", func(t *testing.T) {
+		decodeError(t, c, []byte{0x82, 0xa1, 0x61, 0x01, 0xa1, 0x61, 0x02})
+	})
+
+	t.Run("int keys", func(t *testing.T) {
+		c := msgpack.MapOf(msgpack.Int64, msgpack.Int64)
+		err := decodeError(t, c, []byte{0x81, 0xcd, 0x01, 0x2c, 0xc3})
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) || de.Path != `$["300"]` {
+			report(t, err, `at $["300"]`)
+		}
+		// Keys only need formatting for an error so more entries
+		// shouldn't mean more allocations
+		one := []byte{0x81, 0xcd, 0x01, 0x2c, 0x01}
+		two := []byte{0x82, 0xcd, 0x01, 0x2c, 0x01, 0xcd, 0x01, 0x2d, 0x02}
+		a1 := testing.AllocsPerRun(100, func() {
+			c.Decode(msgpack.NewDecoder(one))
+		})
+		a2 := testing.AllocsPerRun(100, func() {
+			c.Decode(msgpack.NewDecoder(two))
+		})
+		if a2 != a1 {
+			report(t, a2, a1)
+		}
+	})
+
+	t.Run("float keys", func(t *testing.T) {
+		c := msgpack.MapOf(msgpack.Float64, msgpack.Int64)
+		err := decodeError(t, c, []byte{0x81, 0xca, 0x3f, 0xc0, 0x00, 0x00, 0xc3})
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) || de.Path != `$["1.5"]` {
+			report(t, err, `at $["1.5"]`)
+		}
+	})
+
+	t.Run("strict canonical", func(t *testing.T) {
+		b := []byte{0x82, 0xa1, 0x62, 0x01, 0xa1, 0x61, 0x02}
+		if _, err := c.Decode(msgpack.NewDecoder(b)); err != nil {
+			report(t, err, nil)
+		}
+		_, err := c.Decode(msgpack.NewDecoder(b, msgpack.WithStrictCanonical()))
+		if !errors.Is(err, msgpack.ErrNotCanonical) {
+			report(t, err, msgpack.ErrNotCanonical)
+		}
+	})
+}
+
+func TestPtrOf(t *testing.T) {
+	c := msgpack.PtrOf(msgpack.String)
+
+	s := "abc"
+	a := roundTrip(t, c, &s, "a3 61 62 63")
+	if a == nil || *a != s {
+		report(t, a, s)
+	}
+	a = roundTrip(t, c, nil, "c0")
+	if a != nil {
+		report(t, a, nil)
+	}
+}
+
+func TestNullable(t *testing.T) {
+	c := msgpack.Nullable(msgpack.Int64)
+
+	a := roundTrip(t, c, 5, "05")
+	if a != 5 {
+		report(t, a, 5)
+	}
+	a = roundTrip(t, c, 0, "c0")
+	if a != 0 {
+		report(t, a, 0)
+	}
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestContainer(t *testing.T) {
+	run := func(t *testing.T, opts []msgpack.EncoderOption, put func(*msgpack.Encoder), e string) {
+		mpe := msgpack.NewEncoder(opts...)
+		put(mpe)
+		mps := mpe.AsString(-1)
+		if mps != e {
+			report(t, mps, e)
+		}
+	}
+
+	t.Run("empty array", func(t *testing.T) {
+		run(t, nil, func(e *msgpack.Encoder) {
+			e.BeginArray().End()
+		}, "90")
+	})
+
+	t.Run("array", func(t *testing.T) {
+		run(t, nil, func(e *msgpack.Encoder) {
+			e.PutNil()
+			a := e.BeginArray()
+			for i := range 5 {
+				if i%2 == 0 {
+					e.PutInt(int64(i))
+				}
+			}
+			a.End()
+			e.PutNil()
+		}, "c0 93 00 02 04 c0")
+	})
+
+	t.Run("16 bit array", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		a := mpe.BeginArray()
+		for range 16 {
+			mpe.PutNil()
+		}
+		a.End()
+		e := "dc 00 10 " + strings.Repeat("c0 ", 15) + "c0"
+		if mpe.AsString(-1) != e {
+			report(t, mpe.AsString(-1), e)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		run(t, nil, func(e *msgpack.Encoder) {
+			m := e.BeginMap()
+			e.PutString("a")
+			a := e.BeginArray()
+			e.PutInt(1)
+			e.BeginMap().End()
+			a.End()
+			e.PutString("b")
+			e.PutBool(true)
+			m.End()
+		}, "82 a1 61 92 01 80 a1 62 c3")
+	})
+
+	t.Run("fixed headers", func(t *testing.T) {
+		run(t, []msgpack.EncoderOption{msgpack.WithFixedHeaders()}, func(e *msgpack.Encoder) {
+			m := e.BeginMap()
+			e.PutString("a")
+			a := e.BeginArray()
+			e.PutInt(1)
+			a.End()
+			m.End()
+		}, "df 00 00 00 01 a1 61 dd 00 00 00 01 01")
+	})
+
+	t.Run("canonical", func(t *testing.T) {
+		opts := []msgpack.EncoderOption{msgpack.WithCanonical(), msgpack.WithFixedHeaders()}
+		run(t, opts, func(e *msgpack.Encoder) {
+			m := e.BeginMap()
+			e.PutString("b")
+			e.PutInt(1)
+			e.PutString("a")
+			e.PutInt(2)
+			m.End()
+		}, "82 a1 61 02 a1 62 01")
+
+		mpe := msgpack.NewEncoder(msgpack.WithCanonical())
+		m := mpe.BeginMap()
+		mpe.PutString("a")
+		mpe.PutInt(1)
+		mpe.PutString("a")
+		mpe.PutInt(2)
+		if err := m.End(); err == nil {
+			report(t, err, "duplicate key error")
+		}
+	})
+
+	t.Run("unbalanced", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		a := mpe.BeginArray()
+		m := mpe.BeginMap()
+		if err := a.End(); !errors.Is(err, msgpack.ErrUnbalanced) {
+			report(t, err, msgpack.ErrUnbalanced)
+		}
+		if err := m.End(); err != nil {
+			report(t, err, nil)
+		}
+		if err := m.End(); !errors.Is(err, msgpack.ErrUnbalanced) {
+			report(t, err, msgpack.ErrUnbalanced)
+		}
+		if err := mpe.Flush(); !errors.Is(err, msgpack.ErrUnbalanced) {
+			report(t, err, msgpack.ErrUnbalanced)
+		}
+		if err := a.End(); err != nil {
+			report(t, err, nil)
+		}
+		if err := (msgpack.Container{}).End(); !errors.Is(err, msgpack.ErrUnbalanced) {
+			report(t, err, msgpack.ErrUnbalanced)
+		}
+		if mpe.AsString(-1) != "91 80" {
+			report(t, mpe.AsString(-1), "91 80")
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		c1 := mpe.BeginArray()
+		mpe.Clear()
+		c2 := mpe.BeginMap()
+		mpe.PutInt(1)
+		mpe.PutInt(2)
+		if err := c1.End(); !errors.Is(err, msgpack.ErrUnbalanced) {
+			report(t, err, msgpack.ErrUnbalanced)
+		}
+		if err := c2.End(); err != nil {
+			report(t, err, nil)
+		}
+		c3 := mpe.BeginArray()
+		mpe.Reset()
+		if err := c3.End(); !errors.Is(err, msgpack.ErrUnbalanced) {
+			report(t, err, msgpack.ErrUnbalanced)
+		}
+		c4 := mpe.BeginArray()
+		if err := c4.End(); err != nil {
+			report(t, err, nil)
+		}
+		mpe.BeginArray()
+		if err := c4.End(); !errors.Is(err, msgpack.ErrUnbalanced) {
+			report(t, err, msgpack.ErrUnbalanced)
+		}
+	})
+
+	t.Run("odd map", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutNil()
+		m := mpe.BeginMap()
+		mpe.PutString("a")
+		if err := m.End(); err == nil {
+			report(t, err, "error")
+		}
+		if mpe.AsString(-1) != "c0" {
+			report(t, mpe.AsString(-1), "c0")
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		mpe := msgpack.NewStreamEncoder(&buf)
+		a := mpe.BeginArray()
+		for range 3 {
+			mpe.PutString(strings.Repeat("x", 5000))
+		}
+		if buf.Len() != 0 {
+			report(t, buf.Len(), 0)
+		}
+		a.End()
+		mpe.Flush()
+		mpd := msgpack.NewDecoder(buf.Bytes())
+		n, err := mpd.GetArrayLength()
+		if err != nil || n != 3 {
+			report(t, n, 3)
+		}
+		if err := mpd.Skip(); err != nil {
+			report(t, err, nil)
+		}
+		if mpd.Length() != 2*5003 {
+			report(t, mpd.Length(), 2*5003)
+		}
+	})
+}
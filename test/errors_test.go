@@ -0,0 +1,157 @@
+package test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestTypeError(t *testing.T) {
+	run := func(t *testing.T, b []byte, get func(*msgpack.Decoder) error, e msgpack.TypeError) {
+		mpd := msgpack.NewDecoder(b)
+		err := get(mpd)
+		var a *msgpack.TypeError
+		if !errors.As(err, &a) {
+			report(t, err, e)
+		}
+		if *a != e {
+			report(t, *a, e)
+		}
+	}
+
+	t.Run("int", func(t *testing.T) {
+		run(t, []byte{0xc3}, func(d *msgpack.Decoder) error {
+			_, err := d.GetInt()
+			return err
+		}, msgpack.TypeError{Expected: msgpack.KindInt, Got: 0xc3, Offset: 0})
+	})
+
+	t.Run("string in array", func(t *testing.T) {
+		run(t, []byte{0x92, 0xa1, 0x61, 0xc0}, func(d *msgpack.Decoder) error {
+			d.GetArrayLength()
+			d.GetString()
+			_, err := d.GetString()
+			return err
+		}, msgpack.TypeError{Expected: msgpack.KindString, Got: 0xc0, Offset: 3})
+	})
+
+	t.Run("timestamp type", func(t *testing.T) {
+		run(t, []byte{0xd6, 0x01, 0, 0, 0, 0}, func(d *msgpack.Decoder) error {
+			_, err := d.GetTime()
+			return err
+		}, msgpack.TypeError{Expected: msgpack.KindTimestamp, Got: 0x01, Offset: 1})
+	})
+
+	t.Run("message", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0xc3})
+		_, err := mpd.GetInt()
+		e := "at $ (offset 0): invalid byte for int (0xc3)"
+		if err.Error() != e {
+			report(t, err.Error(), e)
+		}
+	})
+}
+
+func TestFormatError(t *testing.T) {
+	mpd := msgpack.NewDecoder([]byte{0x91, 0xc1})
+	err := mpd.Skip()
+	var a *msgpack.FormatError
+	if !errors.As(err, &a) {
+		report(t, err, "format error")
+	}
+	e := msgpack.FormatError{Got: 0xc1, Offset: 1}
+	if *a != e {
+		report(t, *a, e)
+	}
+}
+
+func TestShortBufferError(t *testing.T) {
+	mpd := msgpack.NewDecoder([]byte{0xcd, 0x01})
+	_, err := mpd.GetUint()
+	var a *msgpack.ShortBufferError
+	if !errors.As(err, &a) {
+		report(t, err, "short buffer error")
+	}
+	e := msgpack.ShortBufferError{Need: 2, Have: 1}
+	if *a != e {
+		report(t, *a, e)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		report(t, err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	run := func(t *testing.T, put func(*msgpack.Encoder), e string) {
+		mpe := msgpack.NewEncoder()
+		put(mpe)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		_, err := mpd.GetValue()
+		if err == nil {
+			report(t, err, e)
+		}
+		if err.Error() != e {
+			report(t, err.Error(), e)
+		}
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) {
+			report(t, err, "decode error")
+		}
+	}
+
+	t.Run("top level", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) {
+			e.PutBytes([]byte{0xc1})
+		}, "at $ (offset 0): invalid byte (0xc1)")
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) {
+			e.PutMapLength(1)
+			e.PutString("users")
+			e.PutArrayLength(2)
+			e.PutMapLength(0)
+			e.PutMapLength(1)
+			e.PutString("email")
+			e.PutBytes([]byte{0xc1})
+		}, "at $.users[1].email (offset 16): invalid byte (0xc1)")
+	})
+
+	t.Run("quoted key", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) {
+			e.PutMapLength(1)
+			e.PutString("a b")
+			e.PutBytes([]byte{0xcd, 0x01})
+		}, `at $["a b"] (offset 6): trying to read 1 bytes beyond end of buffer (1 bytes)`)
+	})
+
+	t.Run("manual path", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0x91, 0xc0})
+		mpd.GetArrayLength()
+		mpd.PushIndex(0)
+		_, err := mpd.GetString()
+		mpd.Pop()
+		e := "at $[0] (offset 1): invalid byte for string (0xc0)"
+		if err.Error() != e {
+			report(t, err.Error(), e)
+		}
+		if mpd.Path() != "$" {
+			report(t, mpd.Path(), "$")
+		}
+	})
+}
+
+func TestOffset(t *testing.T) {
+	mpe := msgpack.NewEncoder()
+	mpe.PutString("abc")
+	mpe.PutUint(1000)
+	mpd := msgpack.NewDecoder(mpe.Bytes())
+	for _, e := range []int{0, 4, 7} {
+		if mpd.Offset() != e {
+			report(t, mpd.Offset(), e)
+		}
+		mpd.Skip()
+	}
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/ab36245/go-msgpack"
@@ -54,3 +55,86 @@ func TestExtUint(t *testing.T) {
 		})
 	})
 }
+
+func TestExt(t *testing.T) {
+	run := func(t *testing.T, ti int8, n int, e string) {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		mpe := msgpack.NewEncoder()
+		mpe.PutExt(ti, b)
+		mps := mpe.AsString(len(e)/3 + 1)
+		if mps != e {
+			report(t, mps, e)
+		}
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		ta, a, _ := mpd.GetExt()
+		if ta != ti {
+			report(t, ta, ti)
+		}
+		if !bytes.Equal(a, b) {
+			report(t, a, b)
+		}
+	}
+
+	t.Run("fixext1", func(t *testing.T) {
+		run(t, 42, 1, "d4 2a 00")
+	})
+
+	t.Run("fixext2", func(t *testing.T) {
+		run(t, 42, 2, "d5 2a 00 01")
+	})
+
+	t.Run("fixext4", func(t *testing.T) {
+		run(t, 42, 4, "d6 2a 00 01 02 03")
+	})
+
+	t.Run("fixext8", func(t *testing.T) {
+		run(t, 42, 8, "d7 2a 00 01 02 03 04 05 06 07")
+	})
+
+	t.Run("fixext16", func(t *testing.T) {
+		run(t, 42, 16, "d8 2a 00 01 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f")
+	})
+
+	t.Run("ext8", func(t *testing.T) {
+		t.Run("empty", func(t *testing.T) {
+			run(t, 42, 0, "c7 00 2a")
+		})
+		t.Run("min", func(t *testing.T) {
+			run(t, 42, 3, "c7 03 2a 00 01 02")
+		})
+		t.Run("max", func(t *testing.T) {
+			run(t, 42, 255, "c7 ff 2a 00 01")
+		})
+	})
+
+	t.Run("ext16", func(t *testing.T) {
+		t.Run("min", func(t *testing.T) {
+			run(t, 42, 256, "c8 01 00 2a 00 01")
+		})
+		t.Run("max", func(t *testing.T) {
+			run(t, 42, 65535, "c8 ff ff 2a 00 01")
+		})
+	})
+
+	t.Run("ext32", func(t *testing.T) {
+		t.Run("min", func(t *testing.T) {
+			run(t, 42, 65536, "c9 00 01 00 00 2a 00 01")
+		})
+	})
+
+	t.Run("reserved type", func(t *testing.T) {
+		run(t, -1, 4, "d6 ff 00 01 02 03")
+		run(t, -128, 3, "c7 03 80 00 01 02")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0xc7, 0x04, 0x2a, 0x00})
+		_, _, err := mpd.GetExt()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+}
@@ -100,4 +100,24 @@ func TestFloat(t *testing.T) {
 	t.Run("3.1415", func(t *testing.T) {
 		run(t, 3.1415, "cb 40 09 21 ca c0 83 12 6f")
 	})
+	t.Run("not exact as single", func(t *testing.T) {
+		run(t, (1+0x1p-23)*0x1p-127, "cb 38 00 00 00 20 00 00 00")
+	})
+
+	t.Run("single subnormal", func(t *testing.T) {
+		run(t, 0x1p-149, "ca 00 00 00 01")
+	})
+
+	t.Run("marshal", func(t *testing.T) {
+		type S struct{ F float64 }
+		v := S{(1 + 0x1p-23) * 0x1p-127}
+		b, err := msgpack.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var a S
+		if err := msgpack.Unmarshal(b, &a); err != nil || a != v {
+			report(t, a, v)
+		}
+	})
 }
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestFrame(t *testing.T) {
+	formats := []struct {
+		name   string
+		format msgpack.FrameFormat
+		header string
+	}{
+		{"uint32", msgpack.FrameUint32, "00 00 00 02"},
+		{"varint", msgpack.FrameVarint, "02"},
+		{"binary", msgpack.FrameBinary, "c4 02"},
+	}
+
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			opt := msgpack.WithFrameFormat(f.format)
+
+			t.Run("header", func(t *testing.T) {
+				var buf bytes.Buffer
+				fw := msgpack.NewFrameWriter(&buf, opt)
+				mpe := msgpack.NewEncoder()
+				mpe.PutInt(-1)
+				mpe.PutNil()
+				if err := fw.WriteEncoder(mpe); err != nil {
+					report(t, err, nil)
+				}
+				a := fmt.Sprintf("% x", buf.Bytes())
+				e := f.header + " ff c0"
+				if a != e {
+					report(t, a, e)
+				}
+			})
+
+			t.Run("round trip", func(t *testing.T) {
+				var buf bytes.Buffer
+				fw := msgpack.NewFrameWriter(&buf, opt)
+				sizes := []int{0, 1, 300, 70000}
+				for _, n := range sizes {
+					mpe := msgpack.NewEncoder()
+					mpe.PutBinary(make([]byte, n))
+					fw.WriteEncoder(mpe)
+				}
+				fr := msgpack.NewFrameReader(&buf, opt)
+				for _, n := range sizes {
+					mpd, err := fr.Next()
+					if err != nil {
+						report(t, err, nil)
+						return
+					}
+					b, err := mpd.GetBinary()
+					if err != nil || len(b) != n {
+						report(t, len(b), n)
+					}
+					if !mpd.IsEmpty() {
+						report(t, mpd.Length(), 0)
+					}
+				}
+				if _, err := fr.Next(); err != io.EOF {
+					report(t, err, io.EOF)
+				}
+			})
+
+			t.Run("too long", func(t *testing.T) {
+				var buf bytes.Buffer
+				fw := msgpack.NewFrameWriter(&buf, opt)
+				fw.WriteFrame([]byte{0x01})
+				fw.WriteFrame(bytes.Repeat([]byte{0xc0}, 10))
+				fw.WriteFrame([]byte{0x02})
+
+				small := msgpack.NewFrameWriter(io.Discard, opt, msgpack.WithMaxFrameSize(4))
+				if err := small.WriteFrame(make([]byte, 5)); !errors.Is(err, msgpack.ErrFrameTooLong) {
+					report(t, err, msgpack.ErrFrameTooLong)
+				}
+
+				fr := msgpack.NewFrameReader(&buf, opt, msgpack.WithMaxFrameSize(4))
+				for i, e := range []any{int64(1), msgpack.ErrFrameTooLong, int64(2)} {
+					mpd, err := fr.Next()
+					if i == 1 {
+						if !errors.Is(err, e.(error)) {
+							report(t, err, e)
+						}
+						continue
+					}
+					if err != nil {
+						report(t, err, nil)
+						return
+					}
+					a, err := mpd.GetInt()
+					if err != nil || a != e {
+						report(t, a, e)
+					}
+				}
+			})
+
+			t.Run("truncated", func(t *testing.T) {
+				var buf bytes.Buffer
+				fw := msgpack.NewFrameWriter(&buf, opt)
+				fw.WriteFrame(make([]byte, 300))
+				b := buf.Bytes()
+				for _, n := range []int{1, len(b) - 1} {
+					fr := msgpack.NewFrameReader(bytes.NewReader(b[:n]), opt)
+					if _, err := fr.ReadFrame(); err != io.ErrUnexpectedEOF {
+						report(t, err, io.ErrUnexpectedEOF)
+					}
+				}
+			})
+		})
+	}
+
+	t.Run("varint out of range", func(t *testing.T) {
+		b := binary.AppendUvarint(nil, 1<<63)
+		b = append(b, 1, 1, 1, 2)
+		fr := msgpack.NewFrameReader(bytes.NewReader(b), msgpack.WithFrameFormat(msgpack.FrameVarint))
+		_, err := fr.ReadFrame()
+		if err == nil || errors.Is(err, msgpack.ErrFrameTooLong) {
+			report(t, err, "length out of range")
+		}
+		if _, err2 := fr.ReadFrame(); err2 != err {
+			report(t, err2, err)
+		}
+	})
+
+	t.Run("invalid binary header", func(t *testing.T) {
+		fr := msgpack.NewFrameReader(bytes.NewReader([]byte{0xc0}), msgpack.WithFrameFormat(msgpack.FrameBinary))
+		_, err := fr.ReadFrame()
+		var fe *msgpack.FormatError
+		if !errors.As(err, &fe) || fe.Got != 0xc0 {
+			report(t, err, "*FormatError")
+		}
+	})
+}
@@ -0,0 +1,917 @@
+// Code generated by msgpackgen. DO NOT EDIT.
+
+package gen
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	msgpack "github.com/ab36245/go-msgpack"
+)
+
+// UserCodec encodes and decodes User without reflection
+var UserCodec = msgpack.Codec[User]{
+	Decode: func(d *msgpack.Decoder) (User, error) {
+		var v User
+		err := v.DecodeMsgpack(d)
+		return v, err
+	},
+	Encode: func(e *msgpack.Encoder, v User) error {
+		return v.EncodeMsgpack(e)
+	},
+}
+
+func (v User) EncodeMsgpack(e *msgpack.Encoder) error {
+	n := 14
+	if v.Email != nil {
+		n++
+	}
+	if math.Float64bits(float64(v.Score)) != 0 {
+		n++
+	}
+	if v.Friends != nil {
+		n++
+	}
+	e.PutMapLength(uint32(n))
+	if err := e.PutString("id"); err != nil {
+		return err
+	}
+	e.PutInt(v.Base.ID)
+	if err := e.PutString("hash"); err != nil {
+		return err
+	}
+	if err := e.PutBinary(v.Hash[:]); err != nil {
+		return err
+	}
+	if err := e.PutString("meta"); err != nil {
+		return err
+	}
+	if err := e.PutValue(v.Meta); err != nil {
+		return err
+	}
+	if err := e.PutString("name"); err != nil {
+		return err
+	}
+	if err := e.PutString(v.Name); err != nil {
+		return err
+	}
+	if err := e.PutString("tags"); err != nil {
+		return err
+	}
+	if v.Tags == nil {
+		e.PutNil()
+	} else {
+		e.PutArrayLength(uint32(len(v.Tags)))
+		for _, x1 := range v.Tags {
+			if err := e.PutString(x1); err != nil {
+				return err
+			}
+		}
+	}
+	if v.Email != nil {
+		if err := e.PutString("email"); err != nil {
+			return err
+		}
+		if v.Email == nil {
+			e.PutNil()
+		} else {
+			if err := e.PutString((*v.Email)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := e.PutString("extra"); err != nil {
+		return err
+	}
+	if err := e.Encode(v.Extra); err != nil {
+		return err
+	}
+	if err := e.PutString("level"); err != nil {
+		return err
+	}
+	e.PutInt(int64(v.Level))
+	if err := e.PutString("point"); err != nil {
+		return err
+	}
+	e.PutArrayLength(2)
+	for _, x2 := range v.Point {
+		e.PutInt(int64(x2))
+	}
+	if err := e.PutString("ratio"); err != nil {
+		return err
+	}
+	e.PutFloat32(v.Ratio)
+	if math.Float64bits(float64(v.Score)) != 0 {
+		if err := e.PutString("score"); err != nil {
+			return err
+		}
+		e.PutFloat(v.Score)
+	}
+	if err := e.PutString("active"); err != nil {
+		return err
+	}
+	e.PutBool(v.Active)
+	if err := e.PutString("avatar"); err != nil {
+		return err
+	}
+	if v.Avatar == nil {
+		e.PutNil()
+	} else if err := e.PutBinary(v.Avatar); err != nil {
+		return err
+	}
+	if err := e.PutString("counts"); err != nil {
+		return err
+	}
+	if v.Counts == nil {
+		e.PutNil()
+	} else {
+		c5 := e.BeginMap()
+		for k3, x4 := range v.Counts {
+			if err := func() error {
+				if err := e.PutString(k3); err != nil {
+					return err
+				}
+				e.PutUint(uint64(x4))
+				return nil
+			}(); err != nil {
+				c5.End()
+				return err
+			}
+		}
+		if err := c5.End(); err != nil {
+			return err
+		}
+	}
+	if err := e.PutString("Created"); err != nil {
+		return err
+	}
+	e.PutTime(v.Base.Created)
+	if err := e.PutString("address"); err != nil {
+		return err
+	}
+	if v.Address == nil {
+		e.PutNil()
+	} else {
+		if err := (*v.Address).EncodeMsgpack(e); err != nil {
+			return err
+		}
+	}
+	if v.Friends != nil {
+		if err := e.PutString("friends"); err != nil {
+			return err
+		}
+		if v.Friends == nil {
+			e.PutNil()
+		} else {
+			e.PutArrayLength(uint32(len(v.Friends)))
+			for _, x6 := range v.Friends {
+				if err := x6.EncodeMsgpack(e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *User) DecodeMsgpack(d *msgpack.Decoder) error {
+	isNil, err := d.IfNil()
+	if err != nil {
+		return err
+	}
+	if isNil {
+		*v = User{}
+		return nil
+	}
+	n, err := d.GetMapLength()
+	if err != nil {
+		return err
+	}
+	for range n {
+		if k, err := d.PeekKind(); err != nil {
+			return err
+		} else if k != msgpack.KindString {
+			// Only a string can name a field
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		key, err := d.GetStringView()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			d.PushKey("id")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Base.ID = 0
+				} else if tmp, err := msgpack.GetInteger[int64](d); err != nil {
+					return err
+				} else {
+					v.Base.ID = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "hash":
+			d.PushKey("hash")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Hash = [4]byte{}
+				} else {
+					start := d.Offset()
+					if b, err := d.GetBinaryView(); err != nil {
+						return err
+					} else if len(b) != 4 {
+						return &msgpack.DecodeError{Path: d.Path(), Offset: start, Err: fmt.Errorf("expected 4 bytes, got %d", len(b))}
+					} else {
+						copy(v.Hash[:], b)
+					}
+				}
+				return nil
+			}()
+			d.Pop()
+		case "meta":
+			d.PushKey("meta")
+			err = func() error {
+				if value, err := d.GetValue(); err != nil {
+					return err
+				} else {
+					v.Meta = value
+				}
+				return nil
+			}()
+			d.Pop()
+		case "name":
+			d.PushKey("name")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Name = ""
+				} else if tmp, err := d.GetString(); err != nil {
+					return err
+				} else {
+					v.Name = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "tags":
+			d.PushKey("tags")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Tags = nil
+				} else if n, err := d.GetArrayLength(); err != nil {
+					return err
+				} else {
+					s1 := make([]string, 0, d.ArrayCapacity(n))
+					for i2 := range int(n) {
+						var x3 string
+						d.PushIndex(i2)
+						err := func() error {
+							if isNil, err := d.IfNil(); err != nil {
+								return err
+							} else if isNil {
+								x3 = ""
+							} else if tmp, err := d.GetString(); err != nil {
+								return err
+							} else {
+								x3 = tmp
+							}
+							return nil
+						}()
+						d.Pop()
+						if err != nil {
+							return err
+						}
+						s1 = append(s1, x3)
+					}
+					v.Tags = s1
+				}
+				return nil
+			}()
+			d.Pop()
+		case "email":
+			d.PushKey("email")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Email = nil
+				} else {
+					if v.Email == nil {
+						v.Email = new(string)
+					}
+					if isNil, err := d.IfNil(); err != nil {
+						return err
+					} else if isNil {
+						(*v.Email) = ""
+					} else if tmp, err := d.GetString(); err != nil {
+						return err
+					} else {
+						(*v.Email) = tmp
+					}
+				}
+				return nil
+			}()
+			d.Pop()
+		case "extra":
+			d.PushKey("extra")
+			err = func() error {
+				if err := d.Decode(&v.Extra); err != nil {
+					return err
+				}
+				return nil
+			}()
+			d.Pop()
+		case "level":
+			d.PushKey("level")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Level = 0
+				} else if tmp, err := msgpack.GetInteger[Level](d); err != nil {
+					return err
+				} else {
+					v.Level = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "point":
+			d.PushKey("point")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Point = [2]int{}
+				} else {
+					start := d.Offset()
+					n, err := d.GetArrayLength()
+					if err != nil {
+						return err
+					}
+					if n != 2 {
+						return &msgpack.DecodeError{Path: d.Path(), Offset: start, Err: fmt.Errorf("expected array of 2 items, got %d", n)}
+					}
+					for i4 := range 2 {
+						d.PushIndex(i4)
+						err := func() error {
+							if isNil, err := d.IfNil(); err != nil {
+								return err
+							} else if isNil {
+								v.Point[i4] = 0
+							} else if tmp, err := msgpack.GetInteger[int](d); err != nil {
+								return err
+							} else {
+								v.Point[i4] = tmp
+							}
+							return nil
+						}()
+						d.Pop()
+						if err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			}()
+			d.Pop()
+		case "ratio":
+			d.PushKey("ratio")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Ratio = 0
+				} else if tmp, err := msgpack.GetFloatAs[float32](d); err != nil {
+					return err
+				} else {
+					v.Ratio = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "score":
+			d.PushKey("score")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Score = 0
+				} else if tmp, err := msgpack.GetFloatAs[float64](d); err != nil {
+					return err
+				} else {
+					v.Score = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "active":
+			d.PushKey("active")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Active = false
+				} else if tmp, err := d.GetBool(); err != nil {
+					return err
+				} else {
+					v.Active = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "avatar":
+			d.PushKey("avatar")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Avatar = nil
+				} else if tmp, err := d.GetBinaryCopy(); err != nil {
+					return err
+				} else {
+					v.Avatar = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "counts":
+			d.PushKey("counts")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Counts = nil
+				} else if n, err := d.GetMapLength(); err != nil {
+					return err
+				} else {
+					m5 := make(map[string]uint16, d.MapCapacity(n))
+					for range n {
+						var k6 string
+						if isNil, err := d.IfNil(); err != nil {
+							return err
+						} else if isNil {
+							k6 = ""
+						} else if tmp, err := d.GetString(); err != nil {
+							return err
+						} else {
+							k6 = tmp
+						}
+						var x7 uint16
+						d.PushKeyValue(msgpack.StringValue(k6))
+						err := func() error {
+							if isNil, err := d.IfNil(); err != nil {
+								return err
+							} else if isNil {
+								x7 = 0
+							} else if tmp, err := msgpack.GetInteger[uint16](d); err != nil {
+								return err
+							} else {
+								x7 = tmp
+							}
+							return nil
+						}()
+						d.Pop()
+						if err != nil {
+							return err
+						}
+						m5[k6] = x7
+					}
+					v.Counts = m5
+				}
+				return nil
+			}()
+			d.Pop()
+		case "Created":
+			d.PushKey("Created")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Base.Created = time.Time{}
+				} else if tmp, err := d.GetTime(); err != nil {
+					return err
+				} else {
+					v.Base.Created = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "address":
+			d.PushKey("address")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Address = nil
+				} else {
+					if v.Address == nil {
+						v.Address = new(Address)
+					}
+					if err := (*v.Address).DecodeMsgpack(d); err != nil {
+						return err
+					}
+				}
+				return nil
+			}()
+			d.Pop()
+		case "friends":
+			d.PushKey("friends")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Friends = nil
+				} else if n, err := d.GetArrayLength(); err != nil {
+					return err
+				} else {
+					s8 := make([]Address, 0, d.ArrayCapacity(n))
+					for i9 := range int(n) {
+						var x10 Address
+						d.PushIndex(i9)
+						err := func() error {
+							if err := x10.DecodeMsgpack(d); err != nil {
+								return err
+							}
+							return nil
+						}()
+						d.Pop()
+						if err != nil {
+							return err
+						}
+						s8 = append(s8, x10)
+					}
+					v.Friends = s8
+				}
+				return nil
+			}()
+			d.Pop()
+		default:
+			err = d.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddressCodec encodes and decodes Address without reflection
+var AddressCodec = msgpack.Codec[Address]{
+	Decode: func(d *msgpack.Decoder) (Address, error) {
+		var v Address
+		err := v.DecodeMsgpack(d)
+		return v, err
+	},
+	Encode: func(e *msgpack.Encoder, v Address) error {
+		return v.EncodeMsgpack(e)
+	},
+}
+
+func (v Address) EncodeMsgpack(e *msgpack.Encoder) error {
+	n := 1
+	if v.City != "" {
+		n++
+	}
+	e.PutMapLength(uint32(n))
+	if v.City != "" {
+		if err := e.PutString("city"); err != nil {
+			return err
+		}
+		if err := e.PutString(v.City); err != nil {
+			return err
+		}
+	}
+	if err := e.PutString("street"); err != nil {
+		return err
+	}
+	if err := e.PutString(v.Street); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *Address) DecodeMsgpack(d *msgpack.Decoder) error {
+	isNil, err := d.IfNil()
+	if err != nil {
+		return err
+	}
+	if isNil {
+		*v = Address{}
+		return nil
+	}
+	n, err := d.GetMapLength()
+	if err != nil {
+		return err
+	}
+	for range n {
+		if k, err := d.PeekKind(); err != nil {
+			return err
+		} else if k != msgpack.KindString {
+			// Only a string can name a field
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		key, err := d.GetStringView()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "city":
+			d.PushKey("city")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.City = ""
+				} else if tmp, err := d.GetString(); err != nil {
+					return err
+				} else {
+					v.City = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "street":
+			d.PushKey("street")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Street = ""
+				} else if tmp, err := d.GetString(); err != nil {
+					return err
+				} else {
+					v.Street = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		default:
+			err = d.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TreeCodec encodes and decodes Tree without reflection
+var TreeCodec = msgpack.Codec[Tree]{
+	Decode: func(d *msgpack.Decoder) (Tree, error) {
+		var v Tree
+		err := v.DecodeMsgpack(d)
+		return v, err
+	},
+	Encode: func(e *msgpack.Encoder, v Tree) error {
+		return v.EncodeMsgpack(e)
+	},
+}
+
+func (v Tree) EncodeMsgpack(e *msgpack.Encoder) error {
+	n := 1
+	if v.Index != nil {
+		n++
+	}
+	if v.Children != nil {
+		n++
+	}
+	e.PutMapLength(uint32(n))
+	if v.Index != nil {
+		if err := e.PutString("Index"); err != nil {
+			return err
+		}
+		if v.Index == nil {
+			e.PutNil()
+		} else {
+			c3 := e.BeginMap()
+			for k1, x2 := range v.Index {
+				if err := func() error {
+					e.PutInt(int64(k1))
+					if x2 == nil {
+						e.PutNil()
+					} else {
+						e.PutArrayLength(uint32(len(x2)))
+						for _, x4 := range x2 {
+							if err := x4.EncodeMsgpack(e); err != nil {
+								return err
+							}
+						}
+					}
+					return nil
+				}(); err != nil {
+					c3.End()
+					return err
+				}
+			}
+			if err := c3.End(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := e.PutString("Value"); err != nil {
+		return err
+	}
+	e.PutInt(int64(v.Value))
+	if v.Children != nil {
+		if err := e.PutString("Children"); err != nil {
+			return err
+		}
+		if v.Children == nil {
+			e.PutNil()
+		} else {
+			e.PutArrayLength(uint32(len(v.Children)))
+			for _, x5 := range v.Children {
+				if x5 == nil {
+					e.PutNil()
+				} else {
+					if err := (*x5).EncodeMsgpack(e); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Tree) DecodeMsgpack(d *msgpack.Decoder) error {
+	isNil, err := d.IfNil()
+	if err != nil {
+		return err
+	}
+	if isNil {
+		*v = Tree{}
+		return nil
+	}
+	n, err := d.GetMapLength()
+	if err != nil {
+		return err
+	}
+	for range n {
+		if k, err := d.PeekKind(); err != nil {
+			return err
+		} else if k != msgpack.KindString {
+			// Only a string can name a field
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		key, err := d.GetStringView()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "Index":
+			d.PushKey("Index")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Index = nil
+				} else if n, err := d.GetMapLength(); err != nil {
+					return err
+				} else {
+					m1 := make(map[int][]Tree, d.MapCapacity(n))
+					for range n {
+						var k2 int
+						if isNil, err := d.IfNil(); err != nil {
+							return err
+						} else if isNil {
+							k2 = 0
+						} else if tmp, err := msgpack.GetInteger[int](d); err != nil {
+							return err
+						} else {
+							k2 = tmp
+						}
+						var x3 []Tree
+						d.PushKeyValue(msgpack.IntValue(int64(k2)))
+						err := func() error {
+							if isNil, err := d.IfNil(); err != nil {
+								return err
+							} else if isNil {
+								x3 = nil
+							} else if n, err := d.GetArrayLength(); err != nil {
+								return err
+							} else {
+								s4 := make([]Tree, 0, d.ArrayCapacity(n))
+								for i5 := range int(n) {
+									var x6 Tree
+									d.PushIndex(i5)
+									err := func() error {
+										if err := x6.DecodeMsgpack(d); err != nil {
+											return err
+										}
+										return nil
+									}()
+									d.Pop()
+									if err != nil {
+										return err
+									}
+									s4 = append(s4, x6)
+								}
+								x3 = s4
+							}
+							return nil
+						}()
+						d.Pop()
+						if err != nil {
+							return err
+						}
+						m1[k2] = x3
+					}
+					v.Index = m1
+				}
+				return nil
+			}()
+			d.Pop()
+		case "Value":
+			d.PushKey("Value")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Value = 0
+				} else if tmp, err := msgpack.GetInteger[int](d); err != nil {
+					return err
+				} else {
+					v.Value = tmp
+				}
+				return nil
+			}()
+			d.Pop()
+		case "Children":
+			d.PushKey("Children")
+			err = func() error {
+				if isNil, err := d.IfNil(); err != nil {
+					return err
+				} else if isNil {
+					v.Children = nil
+				} else if n, err := d.GetArrayLength(); err != nil {
+					return err
+				} else {
+					s7 := make([]*Tree, 0, d.ArrayCapacity(n))
+					for i8 := range int(n) {
+						var x9 *Tree
+						d.PushIndex(i8)
+						err := func() error {
+							if isNil, err := d.IfNil(); err != nil {
+								return err
+							} else if isNil {
+								x9 = nil
+							} else {
+								if x9 == nil {
+									x9 = new(Tree)
+								}
+								if err := (*x9).DecodeMsgpack(d); err != nil {
+									return err
+								}
+							}
+							return nil
+						}()
+						d.Pop()
+						if err != nil {
+							return err
+						}
+						s7 = append(s7, x9)
+					}
+					v.Children = s7
+				}
+				return nil
+			}()
+			d.Pop()
+		default:
+			err = d.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
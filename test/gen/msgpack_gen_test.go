@@ -0,0 +1,86 @@
+// Code generated by msgpackgen. DO NOT EDIT.
+
+package gen
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	msgpack "github.com/ab36245/go-msgpack"
+)
+
+func TestUserMsgpack(t *testing.T) {
+	values := []User{
+		{},
+		{Base: Base{ID: 1, Created: time.Unix(1, 0).UTC()}, Name: "a", Level: 1, Score: 1.5, Ratio: 1.5, Active: true, Tags: []string{"a"}, Counts: map[string]uint16{"a": 1}, Avatar: []byte{1}, Hash: [4]byte{1}, Point: [2]int{1}, Address: &Address{Street: "a", City: "a"}, Friends: []Address{Address{Street: "a", City: "a"}}},
+	}
+	for _, v := range values {
+		for _, e := range []*msgpack.Encoder{msgpack.NewEncoder(), msgpack.NewEncoder(msgpack.WithCanonical())} {
+			if err := UserCodec.Encode(e, v); err != nil {
+				t.Fatal(err)
+			}
+			d := msgpack.NewDecoder(e.Bytes())
+			actual, err := UserCodec.Decode(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.IsEmpty() {
+				t.Fatalf("%d bytes left over", d.Length())
+			}
+			if !reflect.DeepEqual(actual, v) {
+				t.Fatalf("\nexpected: %+v\nactual:   %+v\n", v, actual)
+			}
+		}
+	}
+}
+
+func TestAddressMsgpack(t *testing.T) {
+	values := []Address{
+		{},
+		{Street: "a", City: "a"},
+	}
+	for _, v := range values {
+		for _, e := range []*msgpack.Encoder{msgpack.NewEncoder(), msgpack.NewEncoder(msgpack.WithCanonical())} {
+			if err := AddressCodec.Encode(e, v); err != nil {
+				t.Fatal(err)
+			}
+			d := msgpack.NewDecoder(e.Bytes())
+			actual, err := AddressCodec.Decode(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.IsEmpty() {
+				t.Fatalf("%d bytes left over", d.Length())
+			}
+			if !reflect.DeepEqual(actual, v) {
+				t.Fatalf("\nexpected: %+v\nactual:   %+v\n", v, actual)
+			}
+		}
+	}
+}
+
+func TestTreeMsgpack(t *testing.T) {
+	values := []Tree{
+		{},
+		{Value: 1, Children: []*Tree{&Tree{Value: 1}}, Index: map[int][]Tree{1: []Tree{Tree{Value: 1}}}},
+	}
+	for _, v := range values {
+		for _, e := range []*msgpack.Encoder{msgpack.NewEncoder(), msgpack.NewEncoder(msgpack.WithCanonical())} {
+			if err := TreeCodec.Encode(e, v); err != nil {
+				t.Fatal(err)
+			}
+			d := msgpack.NewDecoder(e.Bytes())
+			actual, err := TreeCodec.Decode(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.IsEmpty() {
+				t.Fatalf("%d bytes left over", d.Length())
+			}
+			if !reflect.DeepEqual(actual, v) {
+				t.Fatalf("\nexpected: %+v\nactual:   %+v\n", v, actual)
+			}
+		}
+	}
+}
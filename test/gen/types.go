@@ -0,0 +1,52 @@
+// Package gen holds structs encoded by code from msgpackgen
+package gen
+
+import (
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+//go:generate go run ../../cmd/msgpackgen
+
+type Level int8
+
+type Base struct {
+	ID      int64 `msgpack:"id"`
+	Created time.Time
+}
+
+//msgpack:generate
+type User struct {
+	Base
+	Name    string            `msgpack:"name"`
+	Email   *string           `msgpack:"email,omitempty"`
+	Level   Level             `msgpack:"level"`
+	Score   float64           `msgpack:"score,omitempty"`
+	Ratio   float32           `msgpack:"ratio"`
+	Active  bool              `msgpack:"active"`
+	Tags    []string          `msgpack:"tags"`
+	Counts  map[string]uint16 `msgpack:"counts"`
+	Avatar  []byte            `msgpack:"avatar"`
+	Hash    [4]byte           `msgpack:"hash"`
+	Point   [2]int            `msgpack:"point"`
+	Address *Address          `msgpack:"address"`
+	Friends []Address         `msgpack:"friends,omitempty"`
+	Extra   any               `msgpack:"extra"`
+	Meta    msgpack.Value     `msgpack:"meta"`
+	Ignored string            `msgpack:"-"`
+	secret  string
+}
+
+//msgpack:generate
+type Address struct {
+	Street string `msgpack:"street"`
+	City   string `msgpack:"city,omitempty"`
+}
+
+//msgpack:generate
+type Tree struct {
+	Value    int
+	Children []*Tree        `msgpack:",omitempty"`
+	Index    map[int][]Tree `msgpack:",omitempty"`
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestInteger(t *testing.T) {
+	t.Run("int8", func(t *testing.T) {
+		run := func(t *testing.T, i int64, e error) {
+			mpe := msgpack.NewEncoder()
+			mpe.PutInt(i)
+			mpd := msgpack.NewDecoder(mpe.Bytes())
+			a, err := msgpack.GetInteger[int8](mpd)
+			if e == nil && (err != nil || int64(a) != i) {
+				report(t, a, i)
+			}
+			var oe *msgpack.OverflowError
+			if e != nil && (!errors.As(err, &oe) || oe.Error() != e.Error()) {
+				report(t, err, e)
+			}
+		}
+		t.Run("max", func(t *testing.T) {
+			run(t, 127, nil)
+		})
+		t.Run("min", func(t *testing.T) {
+			run(t, -128, nil)
+		})
+		t.Run("too big", func(t *testing.T) {
+			run(t, 128, &msgpack.OverflowError{Value: int64(128), Type: "int8"})
+		})
+		t.Run("too small", func(t *testing.T) {
+			run(t, -129, &msgpack.OverflowError{Value: int64(-129), Type: "int8"})
+		})
+	})
+
+	t.Run("uint16", func(t *testing.T) {
+		run := func(t *testing.T, put func(*msgpack.Encoder), e uint16, ok bool) {
+			mpe := msgpack.NewEncoder()
+			put(mpe)
+			mpd := msgpack.NewDecoder(mpe.Bytes())
+			a, err := msgpack.GetInteger[uint16](mpd)
+			if (err == nil) != ok {
+				report(t, err, ok)
+			}
+			if a != e {
+				report(t, a, e)
+			}
+		}
+		t.Run("max", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutUint(65535) }, 65535, true)
+		})
+		t.Run("from int", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(1000) }, 1000, true)
+		})
+		t.Run("too big", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutUint(65536) }, 0, false)
+		})
+		t.Run("negative", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(-1) }, 0, false)
+		})
+		t.Run("fraction", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat(0.5) }, 0, false)
+		})
+	})
+
+	t.Run("named type", func(t *testing.T) {
+		type level int32
+		mpe := msgpack.NewEncoder()
+		msgpack.PutInteger(mpe, level(-70000))
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a, err := msgpack.GetInteger[level](mpd)
+		if err != nil || a != -70000 {
+			report(t, a, -70000)
+		}
+	})
+
+	t.Run("put unsigned", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		msgpack.PutInteger(mpe, uint8(200))
+		mps := mpe.AsString(-1)
+		if mps != "cc c8" {
+			report(t, mps, "cc c8")
+		}
+	})
+}
+
+func TestFloatAs(t *testing.T) {
+	run := func(t *testing.T, f float64, e float32, ok bool) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutFloat64(f)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a, err := msgpack.GetFloatAs[float32](mpd)
+		if (err == nil) != ok {
+			report(t, err, ok)
+		}
+		if a != e {
+			report(t, a, e)
+		}
+	}
+
+	t.Run("exact", func(t *testing.T) {
+		run(t, 1.5, 1.5, true)
+	})
+
+	t.Run("inexact", func(t *testing.T) {
+		run(t, 0.1, 0, false)
+	})
+
+	t.Run("too big", func(t *testing.T) {
+		run(t, 1e300, 0, false)
+	})
+
+	t.Run("infinity", func(t *testing.T) {
+		run(t, math.Inf(1), float32(math.Inf(1)), true)
+	})
+
+	t.Run("from int", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutInt(1<<24 + 1)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		_, err := msgpack.GetFloatAs[float32](mpd)
+		var pe *msgpack.PrecisionError
+		if !errors.As(err, &pe) || pe.Type != "float32" {
+			report(t, err, "precision error")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestPeekKind(t *testing.T) {
+	run := func(t *testing.T, put func(*msgpack.Encoder), e msgpack.Kind) {
+		mpe := msgpack.NewEncoder()
+		put(mpe)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a, err := mpd.PeekKind()
+		if err != nil {
+			report(t, err, e)
+		}
+		if a != e {
+			report(t, a, e)
+		}
+		if mpd.Length() != len(mpe.Bytes()) {
+			report(t, mpd.Length(), len(mpe.Bytes()))
+		}
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutNil() }, msgpack.KindNil)
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutBool(true) }, msgpack.KindBool)
+	})
+
+	t.Run("int", func(t *testing.T) {
+		t.Run("positive fixint", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(1) }, msgpack.KindInt)
+		})
+		t.Run("negative fixint", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(-1) }, msgpack.KindInt)
+		})
+		t.Run("16 bit", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(-1000) }, msgpack.KindInt)
+		})
+	})
+
+	t.Run("uint", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutUint(1000) }, msgpack.KindUint)
+	})
+
+	t.Run("float", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutFloat(1.5) }, msgpack.KindFloat)
+	})
+
+	t.Run("string", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutString("abc") }, msgpack.KindString)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutBinary([]byte("abc")) }, msgpack.KindBinary)
+	})
+
+	t.Run("array", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutArrayLength(20) }, msgpack.KindArray)
+	})
+
+	t.Run("map", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutMapLength(2) }, msgpack.KindMap)
+	})
+
+	t.Run("ext", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutExt(1, []byte("abcd")) }, msgpack.KindExt)
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		t.Run("timestamp32", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutTime(time.Unix(1, 0)) }, msgpack.KindTimestamp)
+		})
+		t.Run("timestamp64", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutTime(time.Unix(1, 1)) }, msgpack.KindTimestamp)
+		})
+		t.Run("timestamp96", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutTime(time.Unix(-1, 0)) }, msgpack.KindTimestamp)
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0xc1})
+		_, err := mpd.PeekKind()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		mpd := msgpack.NewDecoder(nil)
+		_, err := mpd.PeekKind()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+}
@@ -0,0 +1,247 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+type marshalBase struct {
+	ID      int64 `msgpack:"id"`
+	Created time.Time
+}
+
+type marshalUser struct {
+	marshalBase
+	Name    string            `msgpack:"name"`
+	Email   *string           `msgpack:"email,omitempty"`
+	Tags    []string          `msgpack:"tags"`
+	Scores  map[string]uint16 `msgpack:"scores"`
+	Avatar  []byte            `msgpack:"avatar"`
+	Hash    [2]byte           `msgpack:"hash"`
+	Point   [2]float32        `msgpack:"point"`
+	Extra   any               `msgpack:"extra"`
+	Ignored string            `msgpack:"-"`
+	secret  string
+}
+
+type marshalTree struct {
+	Value    int
+	Children []*marshalTree `msgpack:",omitempty"`
+}
+
+// Encodes as a single string
+type marshalColour struct {
+	R, G, B uint8
+}
+
+func (c marshalColour) EncodeMsgpack(e *msgpack.Encoder) error {
+	return e.PutString(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+}
+
+func (c *marshalColour) DecodeMsgpack(d *msgpack.Decoder) error {
+	s, err := d.GetString()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return err
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		email := "a@b"
+		v := marshalUser{
+			marshalBase: marshalBase{ID: 1, Created: time.Unix(100, 5).UTC()},
+			Name:        "ann",
+			Email:       &email,
+			Tags:        []string{"x", "y"},
+			Scores:      map[string]uint16{"go": 300},
+			Avatar:      []byte{1, 2, 3},
+			Hash:        [2]byte{4, 5},
+			Point:       [2]float32{1.5, -2},
+			Extra:       map[string]any{"k": []any{int64(1), "s"}},
+			Ignored:     "no",
+			secret:      "no",
+		}
+		b, err := msgpack.Marshal(v)
+		if err != nil {
+			report(t, err, nil)
+		}
+		var a marshalUser
+		if err := msgpack.Unmarshal(b, &a); err != nil {
+			report(t, err, nil)
+		}
+		v.Ignored = ""
+		v.secret = ""
+		if !reflect.DeepEqual(a, v) {
+			report(t, a, v)
+		}
+	})
+
+	t.Run("canonical field order", func(t *testing.T) {
+		b, err := msgpack.Marshal(marshalUser{}, msgpack.WithCanonical())
+		if err != nil {
+			report(t, err, nil)
+		}
+		v, _ := msgpack.NewDecoder(b).GetValue()
+		entries, _ := v.AsMap()
+		var names []string
+		for _, entry := range entries {
+			name, _ := entry.Key.AsString()
+			names = append(names, name)
+		}
+		a := strings.Join(names, " ")
+		e := "id hash name tags extra point avatar scores Created"
+		if a != e {
+			report(t, a, e)
+		}
+	})
+
+	t.Run("omitempty", func(t *testing.T) {
+		b, _ := msgpack.Marshal(marshalTree{Value: 1})
+		mpe := msgpack.NewEncoder()
+		mpe.PutMapLength(1)
+		mpe.PutString("Value")
+		mpe.PutInt(1)
+		if !bytes.Equal(b, mpe.Bytes()) {
+			report(t, b, mpe.Bytes())
+		}
+	})
+
+	t.Run("recursive", func(t *testing.T) {
+		v := &marshalTree{Value: 1, Children: []*marshalTree{{Value: 2}, {Value: 3, Children: []*marshalTree{{Value: 4}}}}}
+		b, err := msgpack.Marshal(v)
+		if err != nil {
+			report(t, err, nil)
+		}
+		var a *marshalTree
+		if err := msgpack.Unmarshal(b, &a); err != nil {
+			report(t, err, nil)
+		}
+		if !reflect.DeepEqual(a, v) {
+			report(t, a, v)
+		}
+	})
+
+	t.Run("marshaler", func(t *testing.T) {
+		v := []marshalColour{{1, 2, 255}}
+		b, err := msgpack.Marshal(v)
+		if err != nil {
+			report(t, err, nil)
+		}
+		mpe := msgpack.NewEncoder()
+		mpe.PutArrayLength(1)
+		mpe.PutString("#0102ff")
+		if !bytes.Equal(b, mpe.Bytes()) {
+			report(t, b, mpe.Bytes())
+		}
+		var a []*marshalColour
+		if err := msgpack.Unmarshal(b, &a); err != nil {
+			report(t, err, nil)
+		}
+		if len(a) != 1 || *a[0] != v[0] {
+			report(t, a, v)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		a := marshalUser{Name: "x", Tags: []string{"y"}}
+		if err := msgpack.Unmarshal([]byte{0xc0}, &a); err != nil {
+			report(t, err, nil)
+		}
+		if !reflect.DeepEqual(a, marshalUser{}) {
+			report(t, a, marshalUser{})
+		}
+		b, _ := msgpack.Marshal(nil)
+		if !bytes.Equal(b, []byte{0xc0}) {
+			report(t, b, []byte{0xc0})
+		}
+	})
+
+	t.Run("ext", func(t *testing.T) {
+		v := msgpack.Ext{Type: 5, Data: []byte{1, 2}}
+		b, err := msgpack.Marshal(v)
+		if err != nil || !bytes.Equal(b, []byte{0xd5, 0x05, 0x01, 0x02}) {
+			report(t, b, []byte{0xd5, 0x05, 0x01, 0x02})
+		}
+		var x any
+		if err := msgpack.Unmarshal(b, &x); err != nil || !reflect.DeepEqual(x, v) {
+			report(t, x, v)
+		}
+		b2, err := msgpack.Marshal(x)
+		if err != nil || !bytes.Equal(b2, b) {
+			report(t, b2, b)
+		}
+		var a msgpack.Ext
+		if err := msgpack.Unmarshal(b, &a); err != nil || !reflect.DeepEqual(a, v) {
+			report(t, a, v)
+		}
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		b, _ := msgpack.Marshal(map[string]any{"Value": 7, "other": []any{1, 2}})
+		var a marshalTree
+		if err := msgpack.Unmarshal(b, &a); err != nil || a.Value != 7 {
+			report(t, a, marshalTree{Value: 7})
+		}
+		b, _ = msgpack.Marshal(map[any]any{1: 2, "Value": 3})
+		a = marshalTree{}
+		if err := msgpack.Unmarshal(b, &a); err != nil || a.Value != 3 {
+			report(t, a, marshalTree{Value: 3})
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var i8 int8
+		err := msgpack.Unmarshal([]byte{0xcc, 0xff}, &i8)
+		var oe *msgpack.OverflowError
+		if !errors.As(err, &oe) {
+			report(t, err, "*OverflowError")
+		}
+
+		var tree marshalTree
+		err = msgpack.Unmarshal([]byte{0x81, 0xa8, 'C', 'h', 'i', 'l', 'd', 'r', 'e', 'n', 0x91, 0x81, 0xa5, 'V', 'a', 'l', 'u', 'e', 0xa0}, &tree)
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) || de.Path != "$.Children[0].Value" {
+			report(t, err, "at $.Children[0].Value")
+		}
+
+		if err := msgpack.Unmarshal([]byte{0x01, 0x02}, &i8); err == nil {
+			report(t, err, "left over error")
+		}
+		if err := msgpack.Unmarshal([]byte{0x01}, i8); err == nil {
+			report(t, err, "pointer error")
+		}
+		if _, err := msgpack.Marshal(make(chan int)); err == nil {
+			report(t, err, "unsupported type error")
+		}
+		var hash [2]byte
+		if err := msgpack.Unmarshal([]byte{0xc4, 0x01, 0x00}, &hash); err == nil {
+			report(t, err, "length error")
+		}
+	})
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := marshalTree{Value: 1, Children: []*marshalTree{{Value: 2}, {Value: 3}}}
+	b.ReportAllocs()
+	for b.Loop() {
+		msgpack.Marshal(v)
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data, _ := msgpack.Marshal(marshalTree{Value: 1, Children: []*marshalTree{{Value: 2}, {Value: 3}}})
+	b.ReportAllocs()
+	for b.Loop() {
+		var v marshalTree
+		msgpack.Unmarshal(data, &v)
+	}
+}
@@ -0,0 +1,177 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+	"github.com/ab36245/go-msgpack/test/gen"
+)
+
+// Converting to these drops the generated methods so Marshal falls
+// back to reflection
+type (
+	plainUser gen.User
+	plainTree gen.Tree
+)
+
+func genUser() gen.User {
+	email := "a@b"
+	return gen.User{
+		Base:    gen.Base{ID: -3, Created: time.Unix(1700000000, 5).UTC()},
+		Name:    "ann",
+		Email:   &email,
+		Level:   -2,
+		Ratio:   0.25,
+		Active:  true,
+		Tags:    []string{"x", "y"},
+		Counts:  map[string]uint16{"b": 2, "a": 1, "c": 300},
+		Avatar:  []byte{1, 2, 3},
+		Hash:    [4]byte{9, 8, 7, 6},
+		Point:   [2]int{-1, 1},
+		Address: &gen.Address{Street: "high st"},
+		Extra:   "anything",
+		Meta:    msgpack.ArrayValue(msgpack.IntValue(1)),
+		Ignored: "not encoded",
+	}
+}
+
+func TestMsgpackgen(t *testing.T) {
+	t.Run("same as reflection", func(t *testing.T) {
+		u := genUser()
+		tree := gen.Tree{
+			Value:    1,
+			Children: []*gen.Tree{{Value: 2}, nil},
+			Index:    map[int][]gen.Tree{3: {{Value: 4}}},
+		}
+		for _, v := range [][2]any{
+			{u, plainUser(u)},
+			{gen.User{}, plainUser{}},
+			{tree, plainTree(tree)},
+		} {
+			a, err := msgpack.Marshal(v[0], msgpack.WithCanonical())
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := msgpack.Marshal(v[1], msgpack.WithCanonical())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(a, e) {
+				report(t, a, e)
+			}
+		}
+	})
+
+	t.Run("decodes reflection", func(t *testing.T) {
+		u := genUser()
+		b, err := msgpack.Marshal(plainUser(u))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := gen.UserCodec.Decode(msgpack.NewDecoder(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.Ignored = ""
+		if !reflect.DeepEqual(a, u) {
+			report(t, a, u)
+		}
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutMapLength(3)
+		mpe.PutInt(1)
+		mpe.PutInt(2)
+		mpe.PutString("extra")
+		mpe.PutValue(msgpack.ArrayValue(msgpack.IntValue(1), msgpack.StringValue("z")))
+		mpe.PutString("street")
+		mpe.PutString("low st")
+		var a gen.Address
+		if err := msgpack.Unmarshal(mpe.Bytes(), &a); err != nil || a.Street != "low st" {
+			report(t, a, gen.Address{Street: "low st"})
+		}
+	})
+
+	t.Run("error path", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutMapLength(1)
+		mpe.PutString("tags")
+		mpe.PutArrayLength(1)
+		mpe.PutInt(1)
+		_, err := gen.UserCodec.Decode(msgpack.NewDecoder(mpe.Bytes()))
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) || de.Path != "$.tags[0]" {
+			report(t, err, "error at $.tags[0]")
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		mpe := msgpack.NewStreamEncoder(&buf)
+		for i := range 3 {
+			if err := gen.TreeCodec.Encode(mpe, gen.Tree{Value: i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := mpe.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		complete := buf.Len()
+		mpe.PutMapLength(1)
+		mpe.PutString("Value")
+		if err := mpe.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		mpd := msgpack.NewStreamDecoder(bytes.NewReader(buf.Bytes()[:complete]))
+		for i := range 3 {
+			a, err := gen.TreeCodec.Decode(mpd)
+			if err != nil || a.Value != i {
+				report(t, a, gen.Tree{Value: i})
+			}
+		}
+		if _, err := gen.TreeCodec.Decode(mpd); err != io.EOF {
+			report(t, err, io.EOF)
+		}
+
+		mpd = msgpack.NewStreamDecoder(bytes.NewReader(buf.Bytes()[complete:]))
+		_, err := gen.TreeCodec.Decode(mpd)
+		var sbe *msgpack.ShortBufferError
+		if !errors.As(err, &sbe) {
+			report(t, err, "short buffer error")
+		}
+	})
+}
+
+func BenchmarkMsgpackgen(b *testing.B) {
+	u := genUser()
+	data, err := msgpack.Marshal(u)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("encode", func(b *testing.B) {
+		e := msgpack.NewEncoder()
+		b.ReportAllocs()
+		for b.Loop() {
+			e.Reset()
+			if err := u.EncodeMsgpack(e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var a gen.User
+			if err := a.DecodeMsgpack(msgpack.NewDecoder(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
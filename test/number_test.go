@@ -0,0 +1,143 @@
+package test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestNumberConversion(t *testing.T) {
+	type result struct {
+		value any
+		err   string
+	}
+	get := func(mpd *msgpack.Decoder, want string) result {
+		var v any
+		var err error
+		switch want {
+		case "int":
+			v, err = mpd.GetInt()
+		case "uint":
+			v, err = mpd.GetUint()
+		case "float":
+			v, err = mpd.GetFloat()
+		}
+		var oe *msgpack.OverflowError
+		var pe *msgpack.PrecisionError
+		switch {
+		case errors.As(err, &oe):
+			return result{nil, "overflow"}
+		case errors.As(err, &pe):
+			return result{nil, "precision"}
+		case err != nil:
+			return result{nil, "invalid"}
+		}
+		return result{v, ""}
+	}
+	run := func(t *testing.T, put func(*msgpack.Encoder), want string, e result) {
+		mpe := msgpack.NewEncoder()
+		put(mpe)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a := get(mpd, want)
+		if a != e {
+			report(t, a, e)
+		}
+	}
+
+	t.Run("int", func(t *testing.T) {
+		t.Run("from uint", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutUint(200) }, "int", result{int64(200), ""})
+		})
+		t.Run("from large uint", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutUint(math.MaxUint64) }, "int", result{nil, "overflow"})
+		})
+		t.Run("from whole float", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat(-3) }, "int", result{int64(-3), ""})
+		})
+		t.Run("from fractional float", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat(1.5) }, "int", result{nil, "precision"})
+		})
+		t.Run("from large float", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat64(1e19) }, "int", result{nil, "overflow"})
+		})
+		t.Run("from nan", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat64(math.NaN()) }, "int", result{nil, "overflow"})
+		})
+		t.Run("from string", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutString("1") }, "int", result{nil, "invalid"})
+		})
+	})
+
+	t.Run("uint", func(t *testing.T) {
+		t.Run("from int", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(300) }, "uint", result{uint64(300), ""})
+		})
+		t.Run("from negative int", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(-1) }, "uint", result{nil, "overflow"})
+		})
+		t.Run("from whole float", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat64(1e19) }, "uint", result{uint64(1e19), ""})
+		})
+		t.Run("from negative float", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutFloat(-1) }, "uint", result{nil, "overflow"})
+		})
+	})
+
+	t.Run("float", func(t *testing.T) {
+		t.Run("from int", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(-5) }, "float", result{-5.0, ""})
+		})
+		t.Run("from uint", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutUint(1 << 60) }, "float", result{float64(1 << 60), ""})
+		})
+		t.Run("from inexact int", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutInt(1<<53 + 1) }, "float", result{nil, "precision"})
+		})
+		t.Run("from max uint", func(t *testing.T) {
+			run(t, func(e *msgpack.Encoder) { e.PutUint(math.MaxUint64) }, "float", result{nil, "precision"})
+		})
+	})
+}
+
+func TestStrictNumbers(t *testing.T) {
+	run := func(t *testing.T, put func(*msgpack.Encoder), get func(*msgpack.Decoder) error, e bool) {
+		mpe := msgpack.NewEncoder()
+		put(mpe)
+		mpd := msgpack.NewDecoder(mpe.Bytes(), msgpack.WithStrictNumbers())
+		err := get(mpd)
+		if (err == nil) != e {
+			report(t, err, e)
+		}
+	}
+	getInt := func(d *msgpack.Decoder) error {
+		_, err := d.GetInt()
+		return err
+	}
+	getUint := func(d *msgpack.Decoder) error {
+		_, err := d.GetUint()
+		return err
+	}
+	getFloat := func(d *msgpack.Decoder) error {
+		_, err := d.GetFloat()
+		return err
+	}
+
+	t.Run("int", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutInt(-200) }, getInt, true)
+		run(t, func(e *msgpack.Encoder) { e.PutUint(200) }, getInt, false)
+		run(t, func(e *msgpack.Encoder) { e.PutFloat(1) }, getInt, false)
+	})
+
+	t.Run("uint", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutUint(1) }, getUint, true)
+		run(t, func(e *msgpack.Encoder) { e.PutUint(200) }, getUint, true)
+		run(t, func(e *msgpack.Encoder) { e.PutInt(200) }, getUint, false)
+	})
+
+	t.Run("float", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutFloat(1) }, getFloat, true)
+		run(t, func(e *msgpack.Encoder) { e.PutInt(1) }, getFloat, false)
+	})
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestParser(t *testing.T) {
+	mpe := msgpack.NewEncoder()
+	mpe.PutInt(1)
+	mpe.PutMapLength(2)
+	mpe.PutString("a")
+	mpe.PutArrayLength(2)
+	mpe.PutBinary(make([]byte, 300))
+	mpe.PutExt(5, []byte("xyz"))
+	mpe.PutString("b")
+	mpe.PutFloat64(1.5)
+	mpe.PutString("last")
+	data := mpe.Bytes()
+
+	var e []msgpack.Raw
+	for v, err := range msgpack.NewDecoder(data).Values() {
+		if err != nil {
+			report(t, err, nil)
+		}
+		e = append(e, v)
+	}
+
+	run := func(t *testing.T, size int) {
+		p := msgpack.NewParser()
+		var a []msgpack.Raw
+		for i := 0; i < len(data); i += size {
+			p.Feed(data[i:min(i+size, len(data))])
+			for {
+				v, err := p.Next()
+				if err == msgpack.ErrNeedMore {
+					break
+				}
+				if err != nil {
+					report(t, err, nil)
+					return
+				}
+				a = append(a, v)
+			}
+		}
+		if len(a) != len(e) {
+			report(t, len(a), len(e))
+			return
+		}
+		for i := range e {
+			if !bytes.Equal(a[i], e[i]) {
+				report(t, a[i], e[i])
+			}
+		}
+		if p.Buffered() != 0 {
+			report(t, p.Buffered(), 0)
+		}
+		if p.Offset() != len(data) {
+			report(t, p.Offset(), len(data))
+		}
+	}
+
+	t.Run("all at once", func(t *testing.T) {
+		run(t, len(data))
+	})
+
+	t.Run("one byte at a time", func(t *testing.T) {
+		run(t, 1)
+	})
+
+	t.Run("odd pieces", func(t *testing.T) {
+		run(t, 7)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		p := msgpack.NewParser()
+		if _, err := p.Next(); err != msgpack.ErrNeedMore {
+			report(t, err, msgpack.ErrNeedMore)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		p := msgpack.NewParser()
+		p.Feed([]byte{0x92, 0x01, 0xc1, 0x02})
+		for range 2 {
+			_, err := p.Next()
+			var fe *msgpack.FormatError
+			if !errors.As(err, &fe) || fe.Got != 0xc1 || fe.Offset != 2 {
+				report(t, err, "*FormatError")
+			}
+		}
+	})
+}
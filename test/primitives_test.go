@@ -0,0 +1,123 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestPrimitives(t *testing.T) {
+	t.Run("bool", func(t *testing.T) {
+		if a := roundTrip(t, msgpack.Bool, true, "c3"); !a {
+			report(t, a, true)
+		}
+	})
+
+	t.Run("ints", func(t *testing.T) {
+		if a := roundTrip(t, msgpack.Int, -1, "ff"); a != -1 {
+			report(t, a, -1)
+		}
+		if a := roundTrip(t, msgpack.Int8, math.MinInt8, "d0 80"); a != math.MinInt8 {
+			report(t, a, math.MinInt8)
+		}
+		if a := roundTrip(t, msgpack.Int16, 300, "d1 01 2c"); a != 300 {
+			report(t, a, 300)
+		}
+		if a := roundTrip(t, msgpack.Int32, math.MaxInt32, "d2 7f ff ff ff"); a != math.MaxInt32 {
+			report(t, a, math.MaxInt32)
+		}
+		if a := roundTrip(t, msgpack.Int64, math.MinInt64, "d3 80 00 00 00 00 00 00 00"); a != math.MinInt64 {
+			report(t, a, math.MinInt64)
+		}
+	})
+
+	t.Run("uints", func(t *testing.T) {
+		if a := roundTrip(t, msgpack.Uint, 1, "01"); a != 1 {
+			report(t, a, 1)
+		}
+		if a := roundTrip(t, msgpack.Uint8, 255, "cc ff"); a != 255 {
+			report(t, a, 255)
+		}
+		if a := roundTrip(t, msgpack.Uint16, 256, "cd 01 00"); a != 256 {
+			report(t, a, 256)
+		}
+		if a := roundTrip(t, msgpack.Uint32, math.MaxUint32, "ce ff ff ff ff"); a != math.MaxUint32 {
+			report(t, a, uint32(math.MaxUint32))
+		}
+		if a := roundTrip(t, msgpack.Uint64, math.MaxUint64, "cf ff ff ff ff ff ff ff ff"); a != math.MaxUint64 {
+			report(t, a, uint64(math.MaxUint64))
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		_, err := msgpack.Int8.Decode(msgpack.NewDecoder([]byte{0xcc, 0x80}))
+		var oe *msgpack.OverflowError
+		if !errors.As(err, &oe) {
+			report(t, err, "*OverflowError")
+		}
+		_, err = msgpack.Float32.Decode(msgpack.NewDecoder([]byte{0xcb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}))
+		var pe *msgpack.PrecisionError
+		if !errors.As(err, &pe) {
+			report(t, err, "*PrecisionError")
+		}
+	})
+
+	t.Run("floats", func(t *testing.T) {
+		if a := roundTrip(t, msgpack.Float32, 1.5, "ca 3f c0 00 00"); a != 1.5 {
+			report(t, a, 1.5)
+		}
+		if a := roundTrip(t, msgpack.Float64, 1.1, "cb 3f f1 99 99 99 99 99 9a"); a != 1.1 {
+			report(t, a, 1.1)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		if a := roundTrip(t, msgpack.String, "ab", "a2 61 62"); a != "ab" {
+			report(t, a, "ab")
+		}
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		b := []byte{0xc4, 0x02, 0x01, 0x02}
+		a, err := msgpack.Bytes.Decode(msgpack.NewDecoder(b))
+		if err != nil || !bytes.Equal(a, []byte{1, 2}) {
+			report(t, a, []byte{1, 2})
+		}
+		b[2] = 9
+		if a[0] != 1 {
+			report(t, a[0], 1)
+		}
+		roundTrip(t, msgpack.Bytes, []byte{1}, "c4 01 01")
+	})
+
+	t.Run("time", func(t *testing.T) {
+		v := time.Unix(1, 0).UTC()
+		if a := roundTrip(t, msgpack.Time, v, "d6 ff 00 00 00 01"); !a.Equal(v) {
+			report(t, a, v)
+		}
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		v := msgpack.Raw{0x91, 0xc0}
+		if a := roundTrip(t, msgpack.RawCodec, v, "91 c0"); !bytes.Equal(a, v) {
+			report(t, a, v)
+		}
+	})
+
+	t.Run("value", func(t *testing.T) {
+		v := msgpack.ArrayValue(msgpack.IntValue(-1))
+		if a := roundTrip(t, msgpack.ValueCodec, v, "91 ff"); !a.Equal(v) {
+			report(t, a, v)
+		}
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		c := msgpack.MapOf(msgpack.String, msgpack.SliceOf(msgpack.PtrOf(msgpack.Uint8)))
+		one := uint8(1)
+		roundTrip(t, c, map[string][]*uint8{"a": {&one, nil}}, "81 a1 61 92 01 c0")
+	})
+}
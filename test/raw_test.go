@@ -0,0 +1,103 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestRaw(t *testing.T) {
+	run := func(t *testing.T, put func(*msgpack.Encoder), e string) {
+		mpe := msgpack.NewEncoder()
+		put(mpe)
+		mpe.PutNil()
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a, err := mpd.GetRaw()
+		if err != nil {
+			report(t, err, nil)
+		}
+		out := msgpack.NewEncoder()
+		err = out.PutRaw(a)
+		if err != nil {
+			report(t, err, nil)
+		}
+		mps := out.AsString(-1)
+		if mps != e {
+			report(t, mps, e)
+		}
+		if mpd.Length() != 1 {
+			report(t, mpd.Length(), 1)
+		}
+	}
+
+	t.Run("scalar", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutInt(-200) }, "d1 ff 38")
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) {
+			e.PutMapLength(1)
+			e.PutString("a")
+			e.PutArrayLength(2)
+			e.PutBool(true)
+			e.PutExt(1, []byte{2})
+		}, "81 a1 61 92 c3 d4 01 02")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0x92, 0xc3})
+		_, err := mpd.GetRaw()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+}
+
+func TestPutRaw(t *testing.T) {
+	run := func(t *testing.T, r msgpack.Raw, ok bool) {
+		mpe := msgpack.NewEncoder()
+		err := mpe.PutRaw(r)
+		if (err == nil) != ok {
+			report(t, err, ok)
+		}
+		if !ok && len(mpe.Bytes()) != 0 {
+			report(t, mpe.AsString(-1), "")
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		run(t, msgpack.Raw{0x91, 0xc0}, true)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		run(t, msgpack.Raw{}, false)
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		run(t, msgpack.Raw{0x92, 0xc0}, false)
+	})
+
+	t.Run("trailing bytes", func(t *testing.T) {
+		run(t, msgpack.Raw{0xc0, 0xc0}, false)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		run(t, msgpack.Raw{0xc1}, false)
+	})
+
+	t.Run("canonical", func(t *testing.T) {
+		mpe := msgpack.NewEncoder(msgpack.WithCanonical())
+		if err := mpe.PutRaw(msgpack.Raw{0xd0, 0x01}); !errors.Is(err, msgpack.ErrNotCanonical) {
+			report(t, err, msgpack.ErrNotCanonical)
+		}
+		if err := mpe.PutRaw(msgpack.Raw{0x01}); err != nil {
+			report(t, err, nil)
+		}
+		type S struct{ R msgpack.Raw }
+		_, err := msgpack.Marshal(S{R: msgpack.Raw{0xd0, 0x01}}, msgpack.WithCanonical())
+		if !errors.Is(err, msgpack.ErrNotCanonical) {
+			report(t, err, msgpack.ErrNotCanonical)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestRollback(t *testing.T) {
+	t.Run("failed record", func(t *testing.T) {
+		mpe := msgpack.NewEncoder(msgpack.WithCanonical())
+		mpe.PutInt(1)
+		mark := mpe.Mark()
+		key := msgpack.StringValue("k")
+		v := msgpack.ArrayValue(
+			msgpack.StringValue("name"),
+			msgpack.MapValue(
+				msgpack.Entry{Key: key, Value: msgpack.NilValue()},
+				msgpack.Entry{Key: key, Value: msgpack.NilValue()},
+			),
+		)
+		if err := mpe.PutValue(v); err == nil {
+			report(t, err, "duplicate key error")
+		}
+		if mpe.Len() == mark {
+			report(t, mpe.Len(), "partial record")
+		}
+		if err := mpe.Rollback(mark); err != nil {
+			report(t, err, nil)
+		}
+		mpe.PutInt(2)
+		if mpe.AsString(-1) != "01 02" {
+			report(t, mpe.AsString(-1), "01 02")
+		}
+		if mpe.Len() != 2 {
+			report(t, mpe.Len(), 2)
+		}
+	})
+
+	t.Run("container ended", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		outer := mpe.Mark()
+		c := mpe.BeginArray()
+		mpe.PutInt(1)
+		inner := mpe.Mark()
+		mpe.PutInt(2)
+		if err := c.End(); err != nil {
+			report(t, err, nil)
+		}
+		mpe.PutString("hello world")
+		if err := mpe.Rollback(inner); err == nil {
+			report(t, err, "error")
+		}
+		if mpe.AsString(5) != "92 01 02 ab 68" {
+			report(t, mpe.AsString(5), "92 01 02 ab 68")
+		}
+		if err := mpe.Rollback(outer); err != nil {
+			report(t, err, nil)
+		}
+		if mpe.Len() != 0 {
+			report(t, mpe.Len(), 0)
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutString("abc")
+		for _, n := range []int{-1, 5} {
+			if err := mpe.Truncate(n); err == nil {
+				report(t, err, "error")
+			}
+		}
+		if err := mpe.Truncate(1); err != nil {
+			report(t, err, nil)
+		}
+		if mpe.AsString(-1) != "a3" {
+			report(t, mpe.AsString(-1), "a3")
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		mpe := msgpack.NewStreamEncoder(&buf)
+		mpe.PutInt(1)
+		mark := mpe.Mark()
+		mpe.PutString("abc")
+		if err := mpe.Rollback(mark); err != nil {
+			report(t, err, nil)
+		}
+		mpe.PutInt(2)
+		mark = mpe.Mark()
+		mpe.PutString(strings.Repeat("x", 5000))
+		if mpe.Len() != 5005 || buf.Len() != 2 {
+			report(t, buf.Len(), 2)
+		}
+		if err := mpe.Rollback(mark); err != nil {
+			report(t, err, nil)
+		}
+		mark = mpe.Mark()
+		mpe.ReleaseMark(mark)
+		mpe.PutString(strings.Repeat("x", 5000))
+		err := mpe.Rollback(mark)
+		if !errors.Is(err, msgpack.ErrFlushed) {
+			report(t, err, msgpack.ErrFlushed)
+		}
+		mpe.Flush()
+		if buf.Len() != 5005 || buf.Bytes()[1] != 0x02 {
+			report(t, buf.Len(), 5005)
+		}
+	})
+
+	t.Run("record crosses buffer", func(t *testing.T) {
+		var buf bytes.Buffer
+		mpe := msgpack.NewStreamEncoder(&buf)
+		mpe.PutBytes(make([]byte, 4091))
+		mark := mpe.Mark()
+		mpe.PutString("0123456789")
+		if buf.Len() != 4091 {
+			report(t, buf.Len(), 4091)
+		}
+		if err := mpe.Rollback(mark); err != nil {
+			report(t, err, nil)
+		}
+		mark = mpe.Mark()
+		mpe.PutInt(1)
+		if err := mpe.Flush(); err != nil {
+			report(t, err, nil)
+		}
+		if buf.Len() != 4091 {
+			report(t, buf.Len(), 4091)
+		}
+		mpe.ReleaseMark(mark)
+		if err := mpe.Flush(); err != nil || buf.Len() != 4092 {
+			report(t, buf.Len(), 4092)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestSkip(t *testing.T) {
+	run := func(t *testing.T, put func(*msgpack.Encoder)) {
+		mpe := msgpack.NewEncoder()
+		put(mpe)
+		mpe.PutString("next")
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		err := mpd.Skip()
+		if err != nil {
+			report(t, err, nil)
+		}
+		a, _ := mpd.GetString()
+		if a != "next" {
+			report(t, a, "next")
+		}
+		if !mpd.IsEmpty() {
+			report(t, mpd.Length(), 0)
+		}
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutNil() })
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutBool(false) })
+	})
+
+	t.Run("int", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutInt(-5) })
+		run(t, func(e *msgpack.Encoder) { e.PutInt(-50000) })
+		run(t, func(e *msgpack.Encoder) { e.PutInt(-5000000000) })
+	})
+
+	t.Run("uint", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutUint(5) })
+		run(t, func(e *msgpack.Encoder) { e.PutUint(500) })
+		run(t, func(e *msgpack.Encoder) { e.PutUint(5000000000) })
+	})
+
+	t.Run("float", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutFloat32(1.5) })
+		run(t, func(e *msgpack.Encoder) { e.PutFloat64(1.5) })
+	})
+
+	t.Run("string", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutString("abc") })
+		run(t, func(e *msgpack.Encoder) { e.PutString(string(make([]byte, 300))) })
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutBinary(make([]byte, 70000)) })
+	})
+
+	t.Run("ext", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutExt(1, make([]byte, 16)) })
+		run(t, func(e *msgpack.Encoder) { e.PutExt(1, make([]byte, 17)) })
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) { e.PutTime(time.Unix(-1, 5)) })
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		run(t, func(e *msgpack.Encoder) {
+			e.PutMapLength(2)
+			e.PutString("a")
+			e.PutArrayLength(3)
+			e.PutInt(1)
+			e.PutMapLength(1)
+			e.PutString("b")
+			e.PutArrayLength(0)
+			e.PutNil()
+			e.PutString("c")
+			e.PutArrayLength(20)
+			for i := range 20 {
+				e.PutInt(int64(i))
+			}
+		})
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutArrayLength(2)
+		mpe.PutInt(1)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		err := mpd.Skip()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0x91, 0xc1})
+		err := mpd.Skip()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+
+	t.Run("allocations", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutArrayLength(2)
+		mpe.PutString("abc")
+		mpe.PutMapLength(1)
+		mpe.PutInt(1)
+		mpe.PutBinary([]byte("abc"))
+		b := mpe.Bytes()
+		a := testing.AllocsPerRun(100, func() {
+			msgpack.NewDecoder(b).Skip()
+		})
+		if a != 0 {
+			report(t, a, 0)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("write failed")
+}
+
+func TestStreamEncoder(t *testing.T) {
+	put := func(e *msgpack.Encoder) {
+		for i := range 1000 {
+			e.PutInt(int64(i * 1000))
+			e.PutString("value")
+		}
+		e.PutBinary(make([]byte, 10000))
+		e.PutFloat(1.5)
+	}
+
+	t.Run("same bytes", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		put(mpe)
+		var buf bytes.Buffer
+		mps := msgpack.NewStreamEncoder(&buf)
+		put(mps)
+		if buf.Len() == 0 {
+			report(t, buf.Len(), "some bytes written before flush")
+		}
+		err := mps.Flush()
+		if err != nil {
+			report(t, err, nil)
+		}
+		if !bytes.Equal(buf.Bytes(), mpe.Bytes()) {
+			report(t, buf.Len(), len(mpe.Bytes()))
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var buf bytes.Buffer
+		mps := msgpack.NewStreamEncoder(&buf, msgpack.WithCanonical())
+		mps.PutInt(200)
+		mps.Flush()
+		e := []byte{0xcc, 0xc8}
+		if !bytes.Equal(buf.Bytes(), e) {
+			report(t, buf.Bytes(), e)
+		}
+	})
+
+	t.Run("sticky error", func(t *testing.T) {
+		w := &failingWriter{}
+		mps := msgpack.NewStreamEncoder(w)
+		put(mps)
+		if mps.Err() == nil {
+			report(t, mps.Err(), "an error")
+		}
+		if err := mps.Flush(); err == nil {
+			report(t, err, "an error")
+		}
+		if w.writes != 1 {
+			report(t, w.writes, 1)
+		}
+	})
+}
+
+func TestStreamDecoder(t *testing.T) {
+	mpe := msgpack.NewEncoder()
+	mpe.PutInt(-1000)
+	mpe.PutString("hello")
+	mpe.PutBinary(bytes.Repeat([]byte{7}, 10000))
+	mpe.PutString(strings.Repeat("x", 5000))
+	mpe.PutValue(msgpack.MapValue(msgpack.Entry{
+		Key:   msgpack.StringValue("a"),
+		Value: msgpack.ArrayValue(msgpack.NilValue(), msgpack.TimeValue(time.Unix(5, 0).UTC())),
+	}))
+	mpe.PutExt(3, []byte("abc"))
+	data := mpe.Bytes()
+
+	get := func(t *testing.T, mpd *msgpack.Decoder) {
+		i, err := mpd.GetInt()
+		if err != nil || i != -1000 {
+			report(t, i, -1000)
+		}
+		s, err := mpd.GetString()
+		if err != nil || s != "hello" {
+			report(t, s, "hello")
+		}
+		b, err := mpd.GetBinary()
+		if err != nil || !bytes.Equal(b, bytes.Repeat([]byte{7}, 10000)) {
+			report(t, len(b), 10000)
+		}
+		s, err = mpd.GetString()
+		if err != nil || s != strings.Repeat("x", 5000) {
+			report(t, len(s), 5000)
+		}
+		v, err := mpd.GetValue()
+		if err != nil {
+			report(t, err, nil)
+		}
+		if a, _ := v.Get("a"); a.Kind() != msgpack.KindArray {
+			report(t, a.Kind(), msgpack.KindArray)
+		}
+		r, err := mpd.GetRaw()
+		e := []byte{0xc7, 0x03, 0x03, 'a', 'b', 'c'}
+		if err != nil || !bytes.Equal(r, e) {
+			report(t, r, e)
+		}
+		_, err = mpd.GetInt()
+		if err != io.EOF {
+			report(t, err, io.EOF)
+		}
+		if mpd.Offset() != len(data) {
+			report(t, mpd.Offset(), len(data))
+		}
+	}
+
+	t.Run("whole reader", func(t *testing.T) {
+		get(t, msgpack.NewStreamDecoder(bytes.NewReader(data)))
+	})
+
+	t.Run("one byte at a time", func(t *testing.T) {
+		get(t, msgpack.NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(data))))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		mpd := msgpack.NewStreamDecoder(bytes.NewReader(nil))
+		if !mpd.IsEmpty() {
+			report(t, mpd.IsEmpty(), true)
+		}
+		_, err := mpd.GetValue()
+		if err != io.EOF {
+			report(t, err, io.EOF)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		run := func(t *testing.T, b []byte, get func(*msgpack.Decoder) error) {
+			mpd := msgpack.NewStreamDecoder(bytes.NewReader(b))
+			err := get(mpd)
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				report(t, err, io.ErrUnexpectedEOF)
+			}
+		}
+		t.Run("scalar", func(t *testing.T) {
+			run(t, []byte{0xcd, 0x01}, func(d *msgpack.Decoder) error {
+				_, err := d.GetUint()
+				return err
+			})
+		})
+		t.Run("array item", func(t *testing.T) {
+			run(t, []byte{0x92, 0x01}, func(d *msgpack.Decoder) error {
+				_, err := d.GetValue()
+				return err
+			})
+		})
+		t.Run("array item with Get methods", func(t *testing.T) {
+			run(t, []byte{0x92, 0x01}, func(d *msgpack.Decoder) error {
+				if _, err := d.GetArrayLength(); err != nil {
+					return err
+				}
+				if _, err := d.GetInt(); err != nil {
+					return err
+				}
+				_, err := d.GetInt()
+				return err
+			})
+		})
+		t.Run("map value with Get methods", func(t *testing.T) {
+			run(t, []byte{0x81, 0xa1, 'a'}, func(d *msgpack.Decoder) error {
+				if _, err := d.GetMapLength(); err != nil {
+					return err
+				}
+				if _, err := d.GetString(); err != nil {
+					return err
+				}
+				_, err := d.IfNil()
+				return err
+			})
+		})
+		t.Run("skipped map value", func(t *testing.T) {
+			run(t, []byte{0x81, 0x01}, func(d *msgpack.Decoder) error {
+				return d.Skip()
+			})
+		})
+		t.Run("large binary", func(t *testing.T) {
+			b := append([]byte{0xc6, 0x00, 0x01, 0x00, 0x00}, make([]byte, 10000)...)
+			run(t, b, func(d *msgpack.Decoder) error {
+				_, err := d.GetBinary()
+				return err
+			})
+		})
+	})
+
+	t.Run("end after array", func(t *testing.T) {
+		mpd := msgpack.NewStreamDecoder(bytes.NewReader([]byte{0x92, 0x01, 0xc0, 0x02}))
+		mpd.GetArrayLength()
+		mpd.GetInt()
+		mpd.IfNil()
+		if _, err := mpd.GetInt(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mpd.GetInt(); err != io.EOF {
+			report(t, err, io.EOF)
+		}
+	})
+
+	t.Run("reader error", func(t *testing.T) {
+		e := errors.New("read failed")
+		r := io.MultiReader(bytes.NewReader([]byte{0x92, 0x01}), iotest.ErrReader(e))
+		mpd := msgpack.NewStreamDecoder(r)
+		_, err := mpd.GetValue()
+		if !errors.Is(err, e) {
+			report(t, err, e)
+		}
+	})
+}
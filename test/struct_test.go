@@ -0,0 +1,135 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+type structUser struct {
+	ID    int64
+	Name  string
+	Tags  []string
+	Email *string
+}
+
+func structUserCodec() *msgpack.StructBuilder[structUser] {
+	return msgpack.Struct[structUser]().
+		Field(msgpack.Field("id", func(u *structUser) *int64 { return &u.ID }, msgpack.Int64)).
+		Field(msgpack.Field("name", func(u *structUser) *string { return &u.Name }, msgpack.String)).
+		Field(msgpack.Field("tags", func(u *structUser) *[]string { return &u.Tags }, msgpack.SliceOf(msgpack.String)).Optional()).
+		Field(msgpack.Field("email", func(u *structUser) **string { return &u.Email }, msgpack.PtrOf(msgpack.String)).Optional())
+}
+
+func TestStruct(t *testing.T) {
+	c := structUserCodec().Build()
+	email := "x@y"
+	u := structUser{ID: 7, Name: "ann", Tags: []string{"a"}, Email: &email}
+
+	t.Run("round trip", func(t *testing.T) {
+		e := "84 a2 69 64 07 a4 6e 61 6d 65 a3 61 6e 6e a4 74 61 67 73 91 a1 61 a5 65 6d 61 69 6c a3 78 40 79"
+		a := roundTrip(t, c, u, e)
+		if a.ID != 7 || a.Name != "ann" || len(a.Tags) != 1 || a.Email == nil || *a.Email != email {
+			report(t, a, u)
+		}
+	})
+
+	t.Run("canonical", func(t *testing.T) {
+		c := msgpack.Struct[structUser]().
+			Field(msgpack.Field("name", func(u *structUser) *string { return &u.Name }, msgpack.String)).
+			Field(msgpack.Field("id", func(u *structUser) *int64 { return &u.ID }, msgpack.Int64)).
+			Build()
+		v := structUser{ID: 1, Name: "a"}
+		roundTrip(t, c, v, "82 a4 6e 61 6d 65 a1 61 a2 69 64 01")
+		roundTrip(t, c, v, "82 a2 69 64 01 a4 6e 61 6d 65 a1 61", msgpack.WithCanonical())
+	})
+
+	t.Run("any order and unknown keys", func(t *testing.T) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutMapLength(4)
+		mpe.PutString("name")
+		mpe.PutString("bob")
+		mpe.PutInt(1)
+		mpe.PutInt(2)
+		mpe.PutString("extra")
+		mpe.PutValue(msgpack.ArrayValue(msgpack.IntValue(1), msgpack.StringValue("z")))
+		mpe.PutString("id")
+		mpe.PutInt(9)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a, err := c.Decode(mpd)
+		if err != nil || a.ID != 9 || a.Name != "bob" || a.Tags != nil || a.Email != nil {
+			report(t, a, structUser{ID: 9, Name: "bob"})
+		}
+		if !mpd.IsEmpty() {
+			report(t, mpd.Length(), 0)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		err := decodeError(t, c, []byte{0x81, 0xa3, 't', 'a', 'g', 's', 0x90})
+		if !strings.Contains(err.Error(), `missing fields "id", "name"`) {
+			report(t, err, `missing fields "id", "name"`)
+		}
+		err = decodeError(t, c, []byte{0x81, 0xa2, 'i', 'd', 0x01})
+		if !strings.Contains(err.Error(), `missing field "name"`) {
+			report(t, err, `missing field "name"`)
+		}
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		decodeError(t, c, []byte{0x82, 0xa2, 'i', 'd', 0x01, 0xa2, 'i', 'd', 0x02})
+	})
+
+	t.Run("bad field", func(t *testing.T) {
+		err := decodeError(t, c, []byte{0x81, 0xa2, 'i', 'd', 0xc3})
+		var de *msgpack.DecodeError
+		if !errors.As(err, &de) || de.Path != "$.id" {
+			report(t, err, "at $.id")
+		}
+	})
+
+	t.Run("strict canonical", func(t *testing.T) {
+		b := []byte{0x82, 0xa4, 'n', 'a', 'm', 'e', 0xa0, 0xa2, 'i', 'd', 0x01}
+		_, err := c.Decode(msgpack.NewDecoder(b, msgpack.WithStrictCanonical()))
+		if !errors.Is(err, msgpack.ErrNotCanonical) {
+			report(t, err, msgpack.ErrNotCanonical)
+		}
+	})
+
+	t.Run("as array", func(t *testing.T) {
+		c := structUserCodec().AsArray().Build()
+		a := roundTrip(t, c, u, "94 07 a3 61 6e 6e 91 a1 61 a3 78 40 79")
+		if a.ID != 7 || a.Email == nil || *a.Email != email {
+			report(t, a, u)
+		}
+
+		// Trailing optional fields can be left off and extra values
+		// are ignored
+		a, err := c.Decode(msgpack.NewDecoder([]byte{0x92, 0x01, 0xa0}))
+		if err != nil || a.ID != 1 {
+			report(t, err, nil)
+		}
+		a, err = c.Decode(msgpack.NewDecoder([]byte{0x95, 0x01, 0xa0, 0xc0, 0xc0, 0x91, 0xc0}))
+		if err != nil || a.ID != 1 {
+			report(t, err, nil)
+		}
+		err = decodeError(t, c, []byte{0x91, 0x01})
+		if !strings.Contains(err.Error(), `missing field "name"`) {
+			report(t, err, `missing field "name"`)
+		}
+	})
+
+	t.Run("duplicate names", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				report(t, nil, "panic")
+			}
+		}()
+		msgpack.Struct[structUser]().
+			Field(msgpack.Field("id", func(u *structUser) *int64 { return &u.ID }, msgpack.Int64)).
+			Field(msgpack.Field("id", func(u *structUser) *string { return &u.Name }, msgpack.String)).
+			Build()
+	})
+}
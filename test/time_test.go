@@ -1,6 +1,7 @@
 package test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -44,3 +45,44 @@ func TestTime(t *testing.T) {
 		)
 	})
 }
+
+func TestTimeRange(t *testing.T) {
+	run := func(t *testing.T, d time.Time) {
+		mpe := msgpack.NewEncoder()
+		mpe.PutTime(d)
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a, _ := mpd.GetTime()
+		if !a.Equal(d) {
+			report(t, a, d)
+		}
+	}
+
+	t.Run("timestamp32 after 2038", func(t *testing.T) {
+		run(t, time.Unix(1<<31+5, 0))
+	})
+
+	t.Run("timestamp64 after 2038", func(t *testing.T) {
+		run(t, time.Unix(1<<33, 5))
+	})
+}
+
+func TestTimeNanoseconds(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"timestamp64": {0xd7, 0xff, 0xee, 0x6b, 0x28, 0x14, 0, 0, 0, 0},
+		"timestamp96": {0xc7, 0x0c, 0xff, 0x3b, 0x9a, 0xca, 0x00, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, opts := range [][]msgpack.DecoderOption{nil, {msgpack.WithStrictCanonical()}} {
+				_, err := msgpack.NewDecoder(b, opts...).GetTime()
+				var de *msgpack.DecodeError
+				if !errors.As(err, &de) {
+					report(t, err, "decode error")
+				}
+			}
+			err := msgpack.NewDecoder(b, msgpack.WithStrictCanonical()).Skip()
+			if err == nil {
+				report(t, err, "error")
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestValue(t *testing.T) {
+	run := func(t *testing.T, v msgpack.Value, e string) {
+		mpe := msgpack.NewEncoder()
+		err := mpe.PutValue(v)
+		if err != nil {
+			report(t, err, nil)
+		}
+		mps := mpe.AsString(-1)
+		if mps != e {
+			report(t, mps, e)
+		}
+		mpd := msgpack.NewDecoder(mpe.Bytes())
+		a, err := mpd.GetValue()
+		if err != nil {
+			report(t, err, nil)
+		}
+		if !a.Equal(v) {
+			report(t, a, v)
+		}
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		run(t, msgpack.NilValue(), "c0")
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		run(t, msgpack.BoolValue(true), "c3")
+	})
+
+	t.Run("int", func(t *testing.T) {
+		run(t, msgpack.IntValue(-200), "d1 ff 38")
+	})
+
+	t.Run("uint", func(t *testing.T) {
+		run(t, msgpack.UintValue(200), "cc c8")
+	})
+
+	t.Run("float", func(t *testing.T) {
+		run(t, msgpack.FloatValue(1.5), "ca 3f c0 00 00")
+	})
+
+	t.Run("string", func(t *testing.T) {
+		run(t, msgpack.StringValue("abc"), "a3 61 62 63")
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		run(t, msgpack.BinaryValue([]byte("abc")), "c4 03 61 62 63")
+	})
+
+	t.Run("ext", func(t *testing.T) {
+		run(t, msgpack.ExtValue(5, []byte("abc")), "c7 03 05 61 62 63")
+	})
+
+	t.Run("timestamp", func(t *testing.T) {
+		run(t, msgpack.TimeValue(time.Unix(1, 0).UTC()), "d6 ff 00 00 00 01")
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		run(t,
+			msgpack.MapValue(
+				msgpack.Entry{
+					Key:   msgpack.StringValue("a"),
+					Value: msgpack.ArrayValue(msgpack.IntValue(-1), msgpack.NilValue()),
+				},
+				msgpack.Entry{
+					Key:   msgpack.IntValue(-2),
+					Value: msgpack.MapValue(),
+				},
+			),
+			"82 a1 61 92 ff c0 fe 80",
+		)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		mpd := msgpack.NewDecoder([]byte{0x92, 0x01})
+		_, err := mpd.GetValue()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+}
+
+func TestValueAccessors(t *testing.T) {
+	v := msgpack.MapValue(
+		msgpack.Entry{Key: msgpack.StringValue("n"), Value: msgpack.IntValue(5)},
+		msgpack.Entry{Key: msgpack.StringValue("s"), Value: msgpack.StringValue("x")},
+	)
+
+	t.Run("get", func(t *testing.T) {
+		n, ok := v.Get("n")
+		if !ok {
+			report(t, ok, true)
+		}
+		if a, _ := n.AsInt(); a != 5 {
+			report(t, a, 5)
+		}
+		if a, _ := n.AsUint(); a != 5 {
+			report(t, a, 5)
+		}
+		if _, ok := v.Get("missing"); ok {
+			report(t, ok, false)
+		}
+	})
+
+	t.Run("wrong kind", func(t *testing.T) {
+		s, _ := v.Get("s")
+		if _, ok := s.AsInt(); ok {
+			report(t, ok, false)
+		}
+		if _, ok := msgpack.IntValue(-1).AsUint(); ok {
+			report(t, ok, false)
+		}
+		if _, ok := msgpack.UintValue(1 << 63).AsInt(); ok {
+			report(t, ok, false)
+		}
+	})
+}
+
+func TestValueAny(t *testing.T) {
+	run := func(t *testing.T, x any, e any) {
+		v, err := msgpack.ValueOf(x)
+		if err != nil {
+			report(t, err, nil)
+		}
+		a, err := v.Any()
+		if err != nil {
+			report(t, err, nil)
+		}
+		if !reflect.DeepEqual(a, e) {
+			report(t, a, e)
+		}
+	}
+
+	t.Run("scalars", func(t *testing.T) {
+		run(t, nil, nil)
+		run(t, true, true)
+		run(t, int8(-3), int64(-3))
+		run(t, uint16(3), uint64(3))
+		run(t, float32(1.5), 1.5)
+		run(t, "abc", "abc")
+		run(t, []byte("abc"), []byte("abc"))
+	})
+
+	t.Run("string keys", func(t *testing.T) {
+		run(t,
+			map[string]any{"a": []any{1, "b"}},
+			map[string]any{"a": []any{int64(1), "b"}},
+		)
+	})
+
+	t.Run("other keys", func(t *testing.T) {
+		run(t,
+			map[any]any{1: true, "a": nil},
+			map[any]any{int64(1): true, "a": nil},
+		)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := msgpack.ValueOf(struct{}{})
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+
+	t.Run("unhashable key", func(t *testing.T) {
+		v := msgpack.MapValue(msgpack.Entry{
+			Key:   msgpack.ArrayValue(),
+			Value: msgpack.NilValue(),
+		})
+		_, err := v.Any()
+		if err == nil {
+			report(t, err, "an error")
+		}
+	})
+}
+
+func TestValueEqual(t *testing.T) {
+	run := func(t *testing.T, v1, v2 msgpack.Value, e bool) {
+		a := v1.Equal(v2)
+		if a != e {
+			report(t, a, e)
+		}
+	}
+
+	t.Run("same", func(t *testing.T) {
+		run(t, msgpack.ArrayValue(msgpack.IntValue(1)), msgpack.ArrayValue(msgpack.IntValue(1)), true)
+	})
+
+	t.Run("different kinds", func(t *testing.T) {
+		run(t, msgpack.IntValue(1), msgpack.UintValue(1), false)
+	})
+
+	t.Run("different items", func(t *testing.T) {
+		run(t, msgpack.ArrayValue(msgpack.IntValue(1)), msgpack.ArrayValue(msgpack.IntValue(2)), false)
+	})
+
+	t.Run("nan", func(t *testing.T) {
+		run(t, msgpack.FloatValue(math.NaN()), msgpack.FloatValue(math.NaN()), true)
+	})
+}
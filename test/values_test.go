@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestValues(t *testing.T) {
+	mpe := msgpack.NewEncoder()
+	mpe.PutInt(1)
+	mpe.PutArrayLength(2)
+	mpe.PutString("a")
+	mpe.PutNil()
+	mpe.PutBool(true)
+	data := mpe.Bytes()
+	e := []string{"01", "92 a1 61 c0", "c3"}
+
+	check := func(t *testing.T, seq func(func(msgpack.Raw, error) bool)) {
+		var a []string
+		for v, err := range seq {
+			if err != nil {
+				report(t, err, nil)
+				return
+			}
+			out := msgpack.NewEncoder()
+			out.PutRaw(v)
+			a = append(a, out.AsString(-1))
+		}
+		if len(a) != len(e) {
+			report(t, a, e)
+			return
+		}
+		for i := range e {
+			if a[i] != e[i] {
+				report(t, a[i], e[i])
+			}
+		}
+	}
+
+	t.Run("decoder", func(t *testing.T) {
+		check(t, msgpack.NewDecoder(data).Values())
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		check(t, msgpack.Values(iotest.OneByteReader(bytes.NewReader(data))))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		for _, err := range msgpack.Values(bytes.NewReader(nil)) {
+			report(t, err, nil)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		mpd := msgpack.NewDecoder(data)
+		for range mpd.Values() {
+			break
+		}
+		if mpd.Offset() != 1 {
+			report(t, mpd.Offset(), 1)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		run := func(t *testing.T, seq func(func(msgpack.Raw, error) bool)) {
+			n := 0
+			var last error
+			for _, err := range seq {
+				n++
+				last = err
+			}
+			if n != 4 {
+				report(t, n, 4)
+			}
+			if !errors.Is(last, io.ErrUnexpectedEOF) {
+				report(t, last, io.ErrUnexpectedEOF)
+			}
+		}
+		b := append(data[:len(data):len(data)], 0x92, 0x01)
+		t.Run("decoder", func(t *testing.T) {
+			run(t, msgpack.NewDecoder(b).Values())
+		})
+		t.Run("reader", func(t *testing.T) {
+			run(t, msgpack.Values(bytes.NewReader(b)))
+		})
+	})
+}
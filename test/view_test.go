@@ -0,0 +1,107 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/ab36245/go-msgpack"
+)
+
+func TestView(t *testing.T) {
+	encode := func() []byte {
+		mpe := msgpack.NewEncoder()
+		mpe.PutBinary([]byte("abc"))
+		mpe.PutString("def")
+		mpe.PutBinary(nil)
+		mpe.PutString("")
+		return mpe.Bytes()
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		data := encode()
+		mpd := msgpack.NewDecoder(data)
+		b, err := mpd.GetBinaryCopy()
+		if err != nil || string(b) != "abc" {
+			report(t, string(b), "abc")
+		}
+		data[2] = 'x'
+		if string(b) != "abc" {
+			report(t, string(b), "abc")
+		}
+	})
+
+	t.Run("view", func(t *testing.T) {
+		data := encode()
+		mpd := msgpack.NewDecoder(data)
+		b, err := mpd.GetBinaryView()
+		if err != nil || string(b) != "abc" {
+			report(t, string(b), "abc")
+		}
+		s, err := mpd.GetStringView()
+		if err != nil || s != "def" {
+			report(t, s, "def")
+		}
+		b = append(b, 'z')
+		if data[5] != 0xa3 {
+			report(t, data[5], 0xa3)
+		}
+		if unsafe.StringData(s) != &data[6] {
+			report(t, unsafe.StringData(s), &data[6])
+		}
+		b, err = mpd.GetBinaryView()
+		if err != nil || len(b) != 0 {
+			report(t, b, nil)
+		}
+		s, err = mpd.GetStringView()
+		if err != nil || s != "" {
+			report(t, s, "")
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		mpd := msgpack.NewStreamDecoder(bytes.NewReader(encode()))
+		b, err := mpd.GetBinaryView()
+		if err != nil || string(b) != "abc" {
+			report(t, string(b), "abc")
+		}
+		s, err := mpd.GetStringView()
+		if err != nil || s != "def" {
+			report(t, s, "def")
+		}
+	})
+
+	t.Run("stream length not trusted", func(t *testing.T) {
+		// Claims a 256MB string but holds one byte of it
+		data := []byte{0x81, 0xdb, 0x10, 0x00, 0x00, 0x00, 0x61}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		var v struct{ A string }
+		err := msgpack.NewStreamDecoder(bytes.NewReader(data)).Decode(&v)
+		runtime.ReadMemStats(&after)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			report(t, err, io.ErrUnexpectedEOF)
+		}
+		if a := after.TotalAlloc - before.TotalAlloc; a > 1<<20 {
+			report(t, a, "under 1MB allocated")
+		}
+	})
+
+	t.Run("allocations", func(t *testing.T) {
+		data := encode()
+		e := testing.AllocsPerRun(100, func() {
+			msgpack.NewDecoder(data)
+		})
+		a := testing.AllocsPerRun(100, func() {
+			mpd := msgpack.NewDecoder(data)
+			mpd.GetBinaryView()
+			mpd.GetStringView()
+		})
+		if a != e {
+			report(t, a, e)
+		}
+	})
+}
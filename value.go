@@ -0,0 +1,401 @@
+package msgpack
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type Value struct {
+	kind    Kind
+	b       bool
+	i       int64
+	u       uint64
+	f       float64
+	s       string
+	data    []byte
+	typ     int8
+	items   []Value
+	entries []Entry
+	t       time.Time
+}
+
+type Entry struct {
+	Key   Value
+	Value Value
+}
+
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+func NilValue() Value {
+	return Value{kind: KindNil}
+}
+
+func BoolValue(v bool) Value {
+	return Value{kind: KindBool, b: v}
+}
+
+func IntValue(v int64) Value {
+	return Value{kind: KindInt, i: v}
+}
+
+func UintValue(v uint64) Value {
+	return Value{kind: KindUint, u: v}
+}
+
+func FloatValue(v float64) Value {
+	return Value{kind: KindFloat, f: v}
+}
+
+func StringValue(v string) Value {
+	return Value{kind: KindString, s: v}
+}
+
+func BinaryValue(v []byte) Value {
+	return Value{kind: KindBinary, data: v}
+}
+
+func ArrayValue(v ...Value) Value {
+	return Value{kind: KindArray, items: v}
+}
+
+func MapValue(v ...Entry) Value {
+	return Value{kind: KindMap, entries: v}
+}
+
+func ExtValue(typ int8, data []byte) Value {
+	return Value{kind: KindExt, typ: typ, data: data}
+}
+
+func TimeValue(v time.Time) Value {
+	return Value{kind: KindTimestamp, t: v}
+}
+
+func ValueOf(v any) (Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return NilValue(), nil
+	case Value:
+		return v, nil
+	case bool:
+		return BoolValue(v), nil
+	case int:
+		return IntValue(int64(v)), nil
+	case int8:
+		return IntValue(int64(v)), nil
+	case int16:
+		return IntValue(int64(v)), nil
+	case int32:
+		return IntValue(int64(v)), nil
+	case int64:
+		return IntValue(v), nil
+	case uint:
+		return UintValue(uint64(v)), nil
+	case uint8:
+		return UintValue(uint64(v)), nil
+	case uint16:
+		return UintValue(uint64(v)), nil
+	case uint32:
+		return UintValue(uint64(v)), nil
+	case uint64:
+		return UintValue(v), nil
+	case float32:
+		return FloatValue(float64(v)), nil
+	case float64:
+		return FloatValue(v), nil
+	case string:
+		return StringValue(v), nil
+	case []byte:
+		return BinaryValue(v), nil
+	case Ext:
+		return ExtValue(v.Type, v.Data), nil
+	case time.Time:
+		return TimeValue(v), nil
+	case []any:
+		items := make([]Value, len(v))
+		for i, x := range v {
+			item, err := ValueOf(x)
+			if err != nil {
+				return Value{}, err
+			}
+			items[i] = item
+		}
+		return ArrayValue(items...), nil
+	case map[string]any:
+		// Go maps are unordered so sort the keys to give a
+		// predictable order
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		entries := make([]Entry, len(keys))
+		for i, k := range keys {
+			value, err := ValueOf(v[k])
+			if err != nil {
+				return Value{}, err
+			}
+			entries[i] = Entry{StringValue(k), value}
+		}
+		return MapValue(entries...), nil
+	case map[any]any:
+		entries := make([]Entry, 0, len(v))
+		for k, x := range v {
+			key, err := ValueOf(k)
+			if err != nil {
+				return Value{}, err
+			}
+			value, err := ValueOf(x)
+			if err != nil {
+				return Value{}, err
+			}
+			entries = append(entries, Entry{key, value})
+		}
+		return MapValue(entries...), nil
+	default:
+		return Value{}, fmt.Errorf("can't convert %T to a value", v)
+	}
+}
+
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+func (v Value) IsNil() bool {
+	return v.kind == KindNil
+}
+
+func (v Value) AsBool() (bool, bool) {
+	return v.b, v.kind == KindBool
+}
+
+func (v Value) AsInt() (int64, bool) {
+	switch v.kind {
+	case KindInt:
+		return v.i, true
+	case KindUint:
+		if v.u <= math.MaxInt64 {
+			return int64(v.u), true
+		}
+	}
+	return 0, false
+}
+
+func (v Value) AsUint() (uint64, bool) {
+	switch v.kind {
+	case KindUint:
+		return v.u, true
+	case KindInt:
+		if v.i >= 0 {
+			return uint64(v.i), true
+		}
+	}
+	return 0, false
+}
+
+func (v Value) AsFloat() (float64, bool) {
+	return v.f, v.kind == KindFloat
+}
+
+func (v Value) AsString() (string, bool) {
+	return v.s, v.kind == KindString
+}
+
+func (v Value) AsBinary() ([]byte, bool) {
+	if v.kind != KindBinary {
+		return nil, false
+	}
+	return v.data, true
+}
+
+func (v Value) AsArray() ([]Value, bool) {
+	return v.items, v.kind == KindArray
+}
+
+func (v Value) AsMap() ([]Entry, bool) {
+	return v.entries, v.kind == KindMap
+}
+
+func (v Value) AsExt() (Ext, bool) {
+	if v.kind != KindExt {
+		return Ext{}, false
+	}
+	return Ext{v.typ, v.data}, true
+}
+
+func (v Value) AsTime() (time.Time, bool) {
+	return v.t, v.kind == KindTimestamp
+}
+
+func (v Value) Equal(o Value) bool {
+	if v.kind != o.kind {
+		return false
+	}
+	switch v.kind {
+	case KindNil:
+		return true
+	case KindBool:
+		return v.b == o.b
+	case KindInt:
+		return v.i == o.i
+	case KindUint:
+		return v.u == o.u
+	case KindFloat:
+		return v.f == o.f || (math.IsNaN(v.f) && math.IsNaN(o.f))
+	case KindString:
+		return v.s == o.s
+	case KindBinary:
+		return bytes.Equal(v.data, o.data)
+	case KindArray:
+		return slices.EqualFunc(v.items, o.items, Value.Equal)
+	case KindMap:
+		return slices.EqualFunc(v.entries, o.entries, func(a, b Entry) bool {
+			return a.Key.Equal(b.Key) && a.Value.Equal(b.Value)
+		})
+	case KindExt:
+		return v.typ == o.typ && bytes.Equal(v.data, o.data)
+	case KindTimestamp:
+		return v.t.Equal(o.t)
+	default:
+		return false
+	}
+}
+
+func (v Value) Get(key string) (Value, bool) {
+	for _, e := range v.entries {
+		if k, ok := e.Key.AsString(); ok && k == key {
+			return e.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// Converts a map key to a Value for a decoder path. Keys of the common
+// types are kept as they are, to be formatted only if the path is,
+// while others are formatted straight away.
+func keyValue[K any](key K) Value {
+	switch k := any(key).(type) {
+	case string:
+		return StringValue(k)
+	case bool:
+		return BoolValue(k)
+	case int:
+		return IntValue(int64(k))
+	case int8:
+		return IntValue(int64(k))
+	case int16:
+		return IntValue(int64(k))
+	case int32:
+		return IntValue(int64(k))
+	case int64:
+		return IntValue(k)
+	case uint:
+		return UintValue(uint64(k))
+	case uint8:
+		return UintValue(uint64(k))
+	case uint16:
+		return UintValue(uint64(k))
+	case uint32:
+		return UintValue(uint64(k))
+	case uint64:
+		return UintValue(k)
+	case float64:
+		return FloatValue(k)
+	default:
+		return StringValue(fmt.Sprint(key))
+	}
+}
+
+// How a map key appears in a decoder path
+func (v Value) pathKey() string {
+	if v.kind == KindString {
+		return v.s
+	}
+	x, err := v.Any()
+	if err != nil {
+		return v.kind.String()
+	}
+	return fmt.Sprint(x)
+}
+
+func (v Value) Any() (any, error) {
+	switch v.kind {
+	case KindNil:
+		return nil, nil
+	case KindBool:
+		return v.b, nil
+	case KindInt:
+		return v.i, nil
+	case KindUint:
+		return v.u, nil
+	case KindFloat:
+		return v.f, nil
+	case KindString:
+		return v.s, nil
+	case KindBinary:
+		return v.data, nil
+	case KindArray:
+		items := make([]any, len(v.items))
+		for i, item := range v.items {
+			x, err := item.Any()
+			if err != nil {
+				return nil, err
+			}
+			items[i] = x
+		}
+		return items, nil
+	case KindMap:
+		return v.anyMap()
+	case KindExt:
+		return Ext{v.typ, v.data}, nil
+	case KindTimestamp:
+		return v.t, nil
+	default:
+		return nil, fmt.Errorf("can't convert value of kind %s", v.kind)
+	}
+}
+
+// Maps with only string keys become map[string]any, anything
+// else becomes map[any]any
+func (v Value) anyMap() (any, error) {
+	strings := true
+	for _, e := range v.entries {
+		if e.Key.kind != KindString {
+			strings = false
+			break
+		}
+	}
+	if strings {
+		m := make(map[string]any, len(v.entries))
+		for _, e := range v.entries {
+			x, err := e.Value.Any()
+			if err != nil {
+				return nil, err
+			}
+			m[e.Key.s] = x
+		}
+		return m, nil
+	}
+	m := make(map[any]any, len(v.entries))
+	for _, e := range v.entries {
+		switch e.Key.kind {
+		case KindBinary, KindArray, KindMap, KindExt:
+			return nil, fmt.Errorf("can't use %s as a map key", e.Key.kind)
+		}
+		k, err := e.Key.Any()
+		if err != nil {
+			return nil, err
+		}
+		x, err := e.Value.Any()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = x
+	}
+	return m, nil
+}
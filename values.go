@@ -0,0 +1,29 @@
+package msgpack
+
+import (
+	"io"
+	"iter"
+)
+
+// Values iterates over the back-to-back values read from r, yielding
+// each one as it is complete. The iteration stops cleanly at the end
+// of r; any other error is yielded once and ends the iteration, as
+// there is no way to find the start of the next value.
+func Values(r io.Reader, opts ...DecoderOption) iter.Seq2[Raw, error] {
+	return NewStreamDecoder(r, opts...).Values()
+}
+
+// Values iterates over the values remaining in d. See Values.
+func (d *Decoder) Values() iter.Seq2[Raw, error] {
+	return func(yield func(Raw, error) bool) {
+		for d.r != nil || !d.IsEmpty() {
+			raw, err := d.GetRaw()
+			if err == io.EOF {
+				return
+			}
+			if !yield(raw, err) || err != nil {
+				return
+			}
+		}
+	}
+}